@@ -0,0 +1,138 @@
+package kate
+
+import (
+	"context"
+	"errors"
+
+	"github.com/stn81/kate/log"
+	"go.uber.org/zap"
+)
+
+const (
+	// AuthSchemeJWT is the scheme of principals authenticated by JWT
+	AuthSchemeJWT = "jwt"
+	// AuthSchemeAPIKey is the scheme of principals authenticated by api key
+	AuthSchemeAPIKey = "apikey"
+	// AuthSchemeHMAC is the scheme of principals authenticated by HMAC request signing
+	AuthSchemeHMAC = "hmac"
+)
+
+// ErrNoCredentials is returned by an Authenticator when the request carries no credentials
+// of its scheme, so that the next authenticator is tried.
+var ErrNoCredentials = errors.New("kate: no credentials")
+
+// Principal is the authenticated identity of a request
+type Principal struct {
+	Subject string         `json:"subject"`
+	Scheme  string         `json:"scheme"`
+	Scopes  []string       `json:"scopes,omitempty"`
+	Claims  map[string]any `json:"claims,omitempty"`
+}
+
+// HasScope reports whether the principal has the scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator authenticates a request
+type Authenticator interface {
+	// Authenticate return the principal of the request, ErrNoCredentials is returned
+	// if the request carries no credentials of this authenticator. Invalid credentials
+	// should be reported by wrapping ErrUnauthorized, other errors are rendered as 500.
+	Authenticate(ctx context.Context, r *Request) (*Principal, error)
+}
+
+// AuthenticatorFunc defines the authenticator func adapter
+type AuthenticatorFunc func(ctx context.Context, r *Request) (*Principal, error)
+
+// Authenticate implements the Authenticator interface
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, r *Request) (*Principal, error) {
+	return f(ctx, r)
+}
+
+type principalCtxMarker struct{}
+
+var principalCtxKey = &principalCtxMarker{}
+
+// PrincipalFrom retrieve the principal in context
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalCtxKey).(*Principal)
+	return p, ok
+}
+
+// WithPrincipal adds the principal to the context for extraction later
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalCtxKey, p)
+}
+
+// Auth implements the authentication middleware. The authenticators are tried in order,
+// the first one finding credentials decides, 401 is sent if none succeeds.
+func Auth(authenticators ...Authenticator) Middleware {
+	mf := func(h ContextHandler) ContextHandler {
+		f := func(ctx context.Context, w ResponseWriter, r *Request) {
+			for _, authenticator := range authenticators {
+				principal, err := authenticator.Authenticate(ctx, r)
+				if errors.Is(err, ErrNoCredentials) {
+					continue
+				}
+				if err != nil {
+					log.GetLogger(ctx).Warn("authenticate failed", zap.Error(err))
+					writeError(ctx, w, err)
+					return
+				}
+				if principal == nil {
+					// 认证器的实现错误，不能放行无主体的请求
+					log.GetLogger(ctx).Error("authenticator returned no principal")
+					writeError(ctx, w, ErrUnauthorized)
+					return
+				}
+
+				ctx = WithPrincipal(ctx, principal)
+				ctx = log.With(ctx, zap.String("principal", principal.Scheme+":"+principal.Subject))
+				h.ServeHTTP(ctx, w, r)
+				return
+			}
+			writeError(ctx, w, ErrUnauthorized)
+		}
+		return ContextHandlerFunc(f)
+	}
+	return MiddlewareFunc(mf)
+}
+
+// Authorize implements the authorization middleware, 403 is sent if allow returns false.
+// It must be used after Auth.
+func Authorize(allow func(ctx context.Context, p *Principal, r *Request) bool) Middleware {
+	mf := func(h ContextHandler) ContextHandler {
+		f := func(ctx context.Context, w ResponseWriter, r *Request) {
+			principal, ok := PrincipalFrom(ctx)
+			if !ok {
+				writeError(ctx, w, ErrUnauthorized)
+				return
+			}
+			if !allow(ctx, principal, r) {
+				writeError(ctx, w, ErrForbidden)
+				return
+			}
+			h.ServeHTTP(ctx, w, r)
+		}
+		return ContextHandlerFunc(f)
+	}
+	return MiddlewareFunc(mf)
+}
+
+// RequireScopes implements the authorization middleware requiring all the scopes
+func RequireScopes(scopes ...string) Middleware {
+	return Authorize(func(_ context.Context, p *Principal, _ *Request) bool {
+		for _, scope := range scopes {
+			if !p.HasScope(scope) {
+				return false
+			}
+		}
+		return true
+	})
+}
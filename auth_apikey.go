@@ -0,0 +1,124 @@
+package kate
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stn81/kate/rdb"
+)
+
+// HeaderAPIKey the default header name carrying api key
+const HeaderAPIKey = "X-API-Key"
+
+// APIKeyStore looks up the principal of api keys
+type APIKeyStore interface {
+	// LookupAPIKey return the principal of key, nil is returned if not found
+	LookupAPIKey(ctx context.Context, key string) (*Principal, error)
+}
+
+// StaticAPIKeys is an APIKeyStore of fixed keys
+type StaticAPIKeys map[string]*Principal
+
+// LookupAPIKey implements the APIKeyStore interface
+func (keys StaticAPIKeys) LookupAPIKey(_ context.Context, key string) (*Principal, error) {
+	var found *Principal
+	for k, principal := range keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			found = principal
+		}
+	}
+	return found, nil
+}
+
+type apiKeyAuthenticator struct {
+	store  APIKeyStore
+	header string
+}
+
+// NewAPIKeyAuthenticator create an authenticator of api key carried in header, header defaults to `X-API-Key`
+func NewAPIKeyAuthenticator(store APIKeyStore, header string) Authenticator {
+	if header == "" {
+		header = HeaderAPIKey
+	}
+	return &apiKeyAuthenticator{
+		store:  store,
+		header: header,
+	}
+}
+
+func (a *apiKeyAuthenticator) Authenticate(ctx context.Context, r *Request) (*Principal, error) {
+	key := r.Header.Get(a.header)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	principal, err := a.store.LookupAPIKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if principal == nil {
+		return nil, fmt.Errorf("%w: unknown api key", ErrUnauthorized)
+	}
+
+	p := *principal
+	p.Scheme = AuthSchemeAPIKey
+	return &p, nil
+}
+
+// RedisAPIKeyStore is an APIKeyStore storing principals in redis, keyed by the sha256 of api keys
+type RedisAPIKeyStore struct {
+	client rdb.Client
+	prefix string
+}
+
+// NewRedisAPIKeyStore create a RedisAPIKeyStore
+func NewRedisAPIKeyStore(client rdb.Client, prefix string) *RedisAPIKeyStore {
+	return &RedisAPIKeyStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// LookupAPIKey implements the APIKeyStore interface
+func (s *RedisAPIKeyStore) LookupAPIKey(ctx context.Context, key string) (*Principal, error) {
+	b, err := s.client.Get(ctx, s.redisKey(key)).Bytes()
+	switch {
+	case errors.Is(err, redis.Nil):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	principal := &Principal{}
+	if err = json.Unmarshal(b, principal); err != nil {
+		return nil, err
+	}
+	return principal, nil
+}
+
+// Put stores the principal of key, ttl 0 means never expire
+func (s *RedisAPIKeyStore) Put(ctx context.Context, key string, principal *Principal, ttl time.Duration) error {
+	b, err := json.Marshal(principal)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.redisKey(key), b, ttl).Err()
+}
+
+// Delete revokes the key
+func (s *RedisAPIKeyStore) Delete(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.redisKey(key)).Err()
+}
+
+// redisKey never stores the raw api key in redis
+func (s *RedisAPIKeyStore) redisKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return s.prefix + hex.EncodeToString(sum[:])
+}
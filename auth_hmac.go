@@ -0,0 +1,253 @@
+package kate
+
+import (
+	"container/heap"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stn81/kate/rdb"
+)
+
+const (
+	// HeaderSignatureKeyId the header name of the signing key id
+	HeaderSignatureKeyId = "X-Signature-Key-Id"
+	// HeaderSignatureTimestamp the header name of the signing unix timestamp in seconds
+	HeaderSignatureTimestamp = "X-Signature-Timestamp"
+	// HeaderSignatureNonce the header name of the signing nonce
+	HeaderSignatureNonce = "X-Signature-Nonce"
+	// HeaderSignature the header name of the hex encoded HMAC-SHA256 signature
+	HeaderSignature = "X-Signature"
+)
+
+// HMACSecretStore looks up the signing secrets
+type HMACSecretStore interface {
+	// LookupSecret return the secret of keyId, nil is returned if not found
+	LookupSecret(ctx context.Context, keyId string) ([]byte, error)
+}
+
+// StaticHMACSecrets is a HMACSecretStore of fixed secrets indexed by key id
+type StaticHMACSecrets map[string][]byte
+
+// LookupSecret implements the HMACSecretStore interface
+func (secrets StaticHMACSecrets) LookupSecret(_ context.Context, keyId string) ([]byte, error) {
+	return secrets[keyId], nil
+}
+
+// NonceStore remembers the nonces seen for replay protection
+type NonceStore interface {
+	// Remember stores the nonce for ttl, it returns false if the nonce is seen before
+	Remember(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// HMACConfig defines the config of HMAC signing authenticator
+type HMACConfig struct {
+	Secrets HMACSecretStore
+	// Nonces rejects the replayed requests within MaxSkew, nil means only timestamp is checked
+	Nonces NonceStore
+	// MaxSkew is the max difference between the signing timestamp and now, default 5m
+	MaxSkew time.Duration
+}
+
+type hmacAuthenticator struct {
+	conf HMACConfig
+}
+
+// NewHMACAuthenticator create an authenticator of HMAC-SHA256 request signing, see SignRequest
+// for how the signature is made.
+func NewHMACAuthenticator(conf HMACConfig) Authenticator {
+	if conf.MaxSkew <= 0 {
+		conf.MaxSkew = 5 * time.Minute
+	}
+	return &hmacAuthenticator{conf: conf}
+}
+
+func (a *hmacAuthenticator) Authenticate(ctx context.Context, r *Request) (*Principal, error) {
+	var (
+		keyId     = r.Header.Get(HeaderSignatureKeyId)
+		timestamp = r.Header.Get(HeaderSignatureTimestamp)
+		nonce     = r.Header.Get(HeaderSignatureNonce)
+		signature = r.Header.Get(HeaderSignature)
+	)
+	if keyId == "" && signature == "" {
+		return nil, ErrNoCredentials
+	}
+	if keyId == "" || timestamp == "" || nonce == "" || signature == "" {
+		return nil, fmt.Errorf("%w: incomplete signature headers", ErrUnauthorized)
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid timestamp", ErrUnauthorized)
+	}
+	if skew := time.Since(time.Unix(ts, 0)); skew > a.conf.MaxSkew || skew < -a.conf.MaxSkew {
+		return nil, fmt.Errorf("%w: timestamp out of range", ErrUnauthorized)
+	}
+
+	secret, err := a.conf.Secrets.LookupSecret(ctx, keyId)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("%w: unknown key id %q", ErrUnauthorized, keyId)
+	}
+
+	expected := signHMAC(secret, r.Method, r.URL.RequestURI(), timestamp, nonce, r.RawBody)
+	if !hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected)) {
+		return nil, fmt.Errorf("%w: invalid signature", ErrUnauthorized)
+	}
+
+	// 验签通过后再记 nonce，避免伪造请求占用 nonce
+	if a.conf.Nonces != nil {
+		fresh, err := a.conf.Nonces.Remember(ctx, keyId+":"+nonce, 2*a.conf.MaxSkew)
+		if err != nil {
+			return nil, err
+		}
+		if !fresh {
+			return nil, fmt.Errorf("%w: replayed request", ErrUnauthorized)
+		}
+	}
+
+	return &Principal{
+		Subject: keyId,
+		Scheme:  AuthSchemeHMAC,
+	}, nil
+}
+
+// SignRequest signs the outgoing request, body must be the exact request body. The signature is
+// hex(HMAC-SHA256(secret, method + "\n" + request uri + "\n" + timestamp + "\n" + nonce + "\n" + hex(sha256(body)))).
+func SignRequest(r *http.Request, body []byte, keyId string, secret []byte) error {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+
+	var (
+		nonce     = hex.EncodeToString(b)
+		timestamp = strconv.FormatInt(time.Now().Unix(), 10)
+	)
+	r.Header.Set(HeaderSignatureKeyId, keyId)
+	r.Header.Set(HeaderSignatureTimestamp, timestamp)
+	r.Header.Set(HeaderSignatureNonce, nonce)
+	r.Header.Set(HeaderSignature, signHMAC(secret, r.Method, r.URL.RequestURI(), timestamp, nonce, body))
+	return nil
+}
+
+func signHMAC(secret []byte, method, uri, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.ToUpper(method)))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(uri))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(nonce))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(hex.EncodeToString(bodyHash[:])))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type memoryNonceStore struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	expires map[string]time.Time
+	queue   nonceQueue
+}
+
+const defaultNonceStoreSize = 100000
+
+// NewMemoryNonceStore create a NonceStore in process memory, it only protects a single
+// instance, use NewRedisNonceStore for a cluster. ttl is used when Remember is called without ttl.
+// The nonces are evicted once expired, at most size live nonces are kept and Remember fails
+// when it is full, rather than forgetting the live nonces which would allow replays.
+// size <= 0 means 100000.
+func NewMemoryNonceStore(size int, ttl time.Duration) NonceStore {
+	if size <= 0 {
+		size = defaultNonceStoreSize
+	}
+	return &memoryNonceStore{
+		size:    size,
+		ttl:     ttl,
+		expires: make(map[string]time.Time),
+	}
+}
+
+func (s *memoryNonceStore) Remember(_ context.Context, nonce string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		ttl = s.ttl
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.evict(now)
+	if _, ok := s.expires[nonce]; ok {
+		return false, nil
+	}
+	if len(s.expires) >= s.size {
+		return false, errors.New("memory nonce store is full")
+	}
+
+	expire := now.Add(ttl)
+	s.expires[nonce] = expire
+	heap.Push(&s.queue, nonceEntry{nonce: nonce, expire: expire})
+	return true, nil
+}
+
+// evict remove the nonces expired at now
+func (s *memoryNonceStore) evict(now time.Time) {
+	for len(s.queue) > 0 && !s.queue[0].expire.After(now) {
+		entry := heap.Pop(&s.queue).(nonceEntry)
+		delete(s.expires, entry.nonce)
+	}
+}
+
+type nonceEntry struct {
+	nonce  string
+	expire time.Time
+}
+
+// nonceQueue is a min-heap of nonces by the expire time
+type nonceQueue []nonceEntry
+
+func (q nonceQueue) Len() int           { return len(q) }
+func (q nonceQueue) Less(i, j int) bool { return q[i].expire.Before(q[j].expire) }
+func (q nonceQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *nonceQueue) Push(x any)        { *q = append(*q, x.(nonceEntry)) }
+
+func (q *nonceQueue) Pop() any {
+	old := *q
+	entry := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return entry
+}
+
+type redisNonceStore struct {
+	client rdb.Client
+	prefix string
+}
+
+// NewRedisNonceStore create a NonceStore in redis
+func NewRedisNonceStore(client rdb.Client, prefix string) NonceStore {
+	return &redisNonceStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (s *redisNonceStore) Remember(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, s.prefix+nonce, 1, ttl).Result()
+}
@@ -0,0 +1,423 @@
+package kate
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// JWTAlgHS256 is HMAC using SHA-256, the key is []byte
+	JWTAlgHS256 = "HS256"
+	// JWTAlgRS256 is RSASSA-PKCS1-v1_5 using SHA-256, the key is *rsa.PublicKey
+	JWTAlgRS256 = "RS256"
+	// JWTAlgES256 is ECDSA using P-256 and SHA-256, the key is *ecdsa.PublicKey
+	JWTAlgES256 = "ES256"
+)
+
+// JWTKeySet provides the verification keys of JWT
+type JWTKeySet interface {
+	// LookupKey return the key of kid, kid is empty if the token header has none
+	LookupKey(kid, alg string) (any, error)
+}
+
+// StaticJWTKeys is a JWTKeySet of fixed keys indexed by kid, the key of "" is used for tokens without kid
+type StaticJWTKeys map[string]any
+
+// LookupKey implements the JWTKeySet interface
+func (keys StaticJWTKeys) LookupKey(kid, _ string) (any, error) {
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown kid %q", kid)
+}
+
+// JWTConfig defines the config of JWT authenticator
+type JWTConfig struct {
+	Keys JWTKeySet
+	// Issuer is checked against the `iss` claim if not empty
+	Issuer string
+	// Audience is checked against the `aud` claim if not empty
+	Audience string
+	// Leeway is the allowed clock skew checking `exp` and `nbf`
+	Leeway time.Duration
+	// AllowMissingExp accepts tokens without the `exp` claim, which never expire
+	AllowMissingExp bool
+}
+
+type jwtAuthenticator struct {
+	conf JWTConfig
+}
+
+// NewJWTAuthenticator create an authenticator of `Authorization: Bearer <jwt>`,
+// HS256, RS256 and ES256 are supported.
+func NewJWTAuthenticator(conf JWTConfig) Authenticator {
+	return &jwtAuthenticator{conf: conf}
+}
+
+func (a *jwtAuthenticator) Authenticate(_ context.Context, r *Request) (*Principal, error) {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+		return nil, ErrNoCredentials
+	}
+
+	claims, err := ParseJWT(strings.TrimSpace(auth[7:]), a.conf)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthorized, err)
+	}
+
+	principal := &Principal{
+		Scheme: AuthSchemeJWT,
+		Claims: claims,
+	}
+	principal.Subject, _ = claims["sub"].(string)
+
+	switch scopes := claims["scope"].(type) {
+	case string:
+		principal.Scopes = strings.Fields(scopes)
+	case []any:
+		for _, scope := range scopes {
+			if s, ok := scope.(string); ok {
+				principal.Scopes = append(principal.Scopes, s)
+			}
+		}
+	}
+	return principal, nil
+}
+
+// ParseJWT verifies the token and return its claims
+func ParseJWT(token string, conf JWTConfig) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("decode header: %v", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("decode signature: %v", err)
+	}
+
+	key, err := conf.Keys.LookupKey(header.Kid, header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	if err = verifyJWTSignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	claims := make(map[string]any)
+	if err = decodeJWTSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("decode claims: %v", err)
+	}
+	if err = validateJWTClaims(claims, conf); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// SignJWT signs the claims, key is []byte for HS256, *rsa.PrivateKey for RS256 and *ecdsa.PrivateKey for ES256
+func SignJWT(alg, kid string, key any, claims map[string]any) (string, error) {
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+
+	headerJson, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJson, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJson) + "." + base64.RawURLEncoding.EncodeToString(claimsJson)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		if alg != JWTAlgHS256 {
+			return "", fmt.Errorf("alg %s mismatch key type %T", alg, key)
+		}
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signingInput))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		if alg != JWTAlgRS256 {
+			return "", fmt.Errorf("alg %s mismatch key type %T", alg, key)
+		}
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+			return "", err
+		}
+	case *ecdsa.PrivateKey:
+		if alg != JWTAlgES256 {
+			return "", fmt.Errorf("alg %s mismatch key type %T", alg, key)
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			return "", err
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	default:
+		return "", fmt.Errorf("unsupported key type %T", key)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// verifyJWTSignature checks the alg against the key type, so that a RSA public key can never be used as HMAC secret
+func verifyJWTSignature(alg string, key any, signingInput string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signingInput))
+
+	switch alg {
+	case JWTAlgHS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("alg %s mismatch key type %T", alg, key)
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return errors.New("invalid signature")
+		}
+	case JWTAlgRS256:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("alg %s mismatch key type %T", alg, key)
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature); err != nil {
+			return errors.New("invalid signature")
+		}
+	case JWTAlgES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve != elliptic.P256() {
+			return fmt.Errorf("alg %s mismatch key type %T", alg, key)
+		}
+		if len(signature) != 64 {
+			return errors.New("invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return errors.New("invalid signature")
+		}
+	default:
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+	return nil
+}
+
+func validateJWTClaims(claims map[string]any, conf JWTConfig) error {
+	now := time.Now()
+
+	if exp, ok := claims["exp"].(float64); ok {
+		if now.After(time.Unix(int64(exp), 0).Add(conf.Leeway)) {
+			return errors.New("token expired")
+		}
+	} else if !conf.AllowMissingExp {
+		return errors.New("missing exp")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok {
+		if now.Add(conf.Leeway).Before(time.Unix(int64(nbf), 0)) {
+			return errors.New("token not valid yet")
+		}
+	}
+	if conf.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != conf.Issuer {
+			return fmt.Errorf("invalid issuer %q", iss)
+		}
+	}
+	if conf.Audience != "" && !jwtAudienceContains(claims["aud"], conf.Audience) {
+		return errors.New("invalid audience")
+	}
+	return nil
+}
+
+func jwtAudienceContains(aud any, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []any:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeJWTSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// JWKSFile is a JWTKeySet loaded from a JWKS json file, the file is reloaded when it is
+// modified so that keys can be rotated by replacing the file.
+type JWKSFile struct {
+	path    string
+	refresh time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]any
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+// NewJWKSFile load the JWKS file, the modification is checked at most once per refresh,
+// and on every unknown kid.
+func NewJWKSFile(path string, refresh time.Duration) (*JWKSFile, error) {
+	f := &JWKSFile{
+		path:    path,
+		refresh: refresh,
+	}
+	if err := f.reload(true); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// LookupKey implements the JWTKeySet interface
+func (f *JWKSFile) LookupKey(kid, _ string) (any, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if time.Since(f.lastCheck) >= f.refresh {
+		_ = f.reload(false)
+	}
+	if key, ok := f.keys[kid]; ok {
+		return key, nil
+	}
+
+	// 轮换时新 kid 可能先于刷新周期出现
+	_ = f.reload(false)
+	if key, ok := f.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown kid %q", kid)
+}
+
+// reload must be called with mu held, except in constructor
+func (f *JWKSFile) reload(force bool) error {
+	f.lastCheck = time.Now()
+
+	stat, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	if !force && stat.ModTime().Equal(f.modTime) {
+		return nil
+	}
+
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	keys, err := ParseJWKS(b)
+	if err != nil {
+		return err
+	}
+
+	f.keys = keys
+	f.modTime = stat.ModTime()
+	return nil
+}
+
+// ParseJWKS parses the JWKS json, RSA, EC P-256 and oct keys are supported.
+// Keys of other types or curves are skipped, an error is returned if no key is supported.
+func ParseJWKS(b []byte) (map[string]any, error) {
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+			K   string `json:"k"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &jwks); err != nil {
+		return nil, fmt.Errorf("parse jwks: %v", err)
+	}
+
+	keys := make(map[string]any, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		switch jwk.Kty {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+			if err != nil {
+				return nil, fmt.Errorf("jwk %q: decode n: %v", jwk.Kid, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+			if err != nil {
+				return nil, fmt.Errorf("jwk %q: decode e: %v", jwk.Kid, err)
+			}
+			keys[jwk.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "EC":
+			if jwk.Crv != "P-256" {
+				continue
+			}
+			x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+			if err != nil {
+				return nil, fmt.Errorf("jwk %q: decode x: %v", jwk.Kid, err)
+			}
+			y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+			if err != nil {
+				return nil, fmt.Errorf("jwk %q: decode y: %v", jwk.Kid, err)
+			}
+			if len(x) != 32 || len(y) != 32 {
+				return nil, fmt.Errorf("jwk %q: invalid P-256 point", jwk.Kid)
+			}
+			pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+			if err != nil {
+				return nil, fmt.Errorf("jwk %q: %v", jwk.Kid, err)
+			}
+			keys[jwk.Kid] = pub
+		case "oct":
+			k, err := base64.RawURLEncoding.DecodeString(jwk.K)
+			if err != nil {
+				return nil, fmt.Errorf("jwk %q: decode k: %v", jwk.Kid, err)
+			}
+			keys[jwk.Kid] = k
+		default:
+			// 同一 JWKS 里可能发布了不支持的密钥(如 OKP)，跳过即可
+			continue
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("parse jwks: no supported key")
+	}
+	return keys, nil
+}
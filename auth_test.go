@@ -0,0 +1,369 @@
+package kate
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// serveAuth 经 Auth 中间件执行请求，返回 handler 看到的 principal（未调用则为 nil）
+func serveAuth(t *testing.T, mw Middleware, req *http.Request, body []byte) (*httptest.ResponseRecorder, *Principal) {
+	t.Helper()
+	var got *Principal
+	h := mw.Proxy(ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		p, ok := PrincipalFrom(ctx)
+		if !ok {
+			t.Error("principal missing in context")
+		}
+		got = p
+		w.WriteHeader(http.StatusOK)
+	}))
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(context.Background(), &responseWriter{ResponseWriter: recorder}, &Request{Request: req, RawBody: body})
+	return recorder, got
+}
+
+func bearerRequest(token string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestJWT_Algorithms(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	secret := []byte("s3cr3t")
+
+	mw := Auth(NewJWTAuthenticator(JWTConfig{
+		Keys: StaticJWTKeys{
+			"hs": secret,
+			"rs": &rsaKey.PublicKey,
+			"es": &ecKey.PublicKey,
+		},
+		Issuer:   "kate",
+		Audience: "api",
+	}))
+
+	cases := []struct {
+		alg, kid string
+		key      any
+	}{
+		{JWTAlgHS256, "hs", secret},
+		{JWTAlgRS256, "rs", rsaKey},
+		{JWTAlgES256, "es", ecKey},
+	}
+	for _, c := range cases {
+		token, err := SignJWT(c.alg, c.kid, c.key, map[string]any{
+			"sub":   "u1",
+			"iss":   "kate",
+			"aud":   []string{"web", "api"},
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"scope": "read write",
+		})
+		if err != nil {
+			t.Fatalf("%s: sign: %v", c.alg, err)
+		}
+
+		rec, p := serveAuth(t, mw, bearerRequest(token), nil)
+		if rec.Code != http.StatusOK || p == nil {
+			t.Fatalf("%s: status = %d, body = %s", c.alg, rec.Code, rec.Body.String())
+		}
+		if p.Subject != "u1" || p.Scheme != AuthSchemeJWT || !p.HasScope("write") {
+			t.Errorf("%s: principal = %+v", c.alg, p)
+		}
+	}
+}
+
+func TestJWT_Rejected(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	secret := []byte("s3cr3t")
+	mw := Auth(NewJWTAuthenticator(JWTConfig{
+		Keys:   StaticJWTKeys{"hs": secret, "rs": &rsaKey.PublicKey},
+		Issuer: "kate",
+	}))
+
+	sign := func(alg, kid string, key any, claims map[string]any) string {
+		token, err := SignJWT(alg, kid, key, claims)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return token
+	}
+	valid := map[string]any{"sub": "u1", "iss": "kate", "exp": time.Now().Add(time.Minute).Unix()}
+	pubDER := rsaKey.PublicKey.N.Bytes()
+
+	cases := map[string]string{
+		"expired":       sign(JWTAlgHS256, "hs", secret, map[string]any{"iss": "kate", "exp": time.Now().Add(-time.Minute).Unix()}),
+		"not yet valid": sign(JWTAlgHS256, "hs", secret, map[string]any{"iss": "kate", "nbf": time.Now().Add(time.Minute).Unix()}),
+		"wrong issuer":  sign(JWTAlgHS256, "hs", secret, map[string]any{"iss": "evil", "exp": time.Now().Add(time.Minute).Unix()}),
+		"missing exp":   sign(JWTAlgHS256, "hs", secret, map[string]any{"sub": "u1", "iss": "kate"}),
+		"wrong secret":  sign(JWTAlgHS256, "hs", []byte("guess"), valid),
+		"unknown kid":   sign(JWTAlgHS256, "nope", secret, valid),
+		// 算法混淆：用公钥材料当 HMAC 密钥，声称 kid 指向 RSA 公钥
+		"alg confusion": sign(JWTAlgHS256, "rs", pubDER, valid),
+		"malformed":     "a.b",
+	}
+	for name, token := range cases {
+		rec, p := serveAuth(t, mw, bearerRequest(token), nil)
+		if rec.Code != http.StatusUnauthorized || p != nil {
+			t.Errorf("%s: status = %d, want 401", name, rec.Code)
+			continue
+		}
+		if got := decodeResult(t, rec.Body.Bytes()); got.ErrNO != errnoUnauthed {
+			t.Errorf("%s: envelope wrong: %+v", name, got)
+		}
+	}
+}
+
+func TestJWT_AllowMissingExp(t *testing.T) {
+	secret := []byte("s3cr3t")
+	mw := Auth(NewJWTAuthenticator(JWTConfig{Keys: StaticJWTKeys{"": secret}, AllowMissingExp: true}))
+
+	token, _ := SignJWT(JWTAlgHS256, "", secret, map[string]any{"sub": "u1"})
+	if rec, p := serveAuth(t, mw, bearerRequest(token), nil); rec.Code != http.StatusOK || p == nil {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+}
+
+func writeJWKS(t *testing.T, path, kid string, pub *rsa.PublicKey, modTime time.Time) {
+	t.Helper()
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":%q,"alg":"RS256","n":%q,"e":%q}]}`,
+		kid,
+		base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()))
+	if err := os.WriteFile(path, []byte(jwks), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseJWKS_SkipUnsupported(t *testing.T) {
+	keys, err := ParseJWKS([]byte(`{"keys":[
+		{"kty":"OKP","kid":"ed","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+		{"kty":"EC","kid":"p384","crv":"P-384","x":"AA","y":"AA"},
+		{"kty":"oct","kid":"hs","k":"c2VjcmV0"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || string(keys["hs"].([]byte)) != "secret" {
+		t.Errorf("keys = %v, want only hs", keys)
+	}
+
+	if _, err = ParseJWKS([]byte(`{"keys":[{"kty":"OKP","kid":"ed","crv":"Ed25519","x":"AA"}]}`)); err == nil {
+		t.Error("ParseJWKS() without supported keys should fail")
+	}
+}
+
+func TestJWT_JWKSFileRotation(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, "k1", &oldKey.PublicKey, time.Now().Add(-time.Hour))
+
+	keys, err := NewJWKSFile(path, time.Hour)
+	if err != nil {
+		t.Fatalf("load jwks: %v", err)
+	}
+	mw := Auth(NewJWTAuthenticator(JWTConfig{Keys: keys}))
+
+	oldToken, _ := SignJWT(JWTAlgRS256, "k1", oldKey, map[string]any{"sub": "u1", "exp": time.Now().Add(time.Minute).Unix()})
+	if rec, _ := serveAuth(t, mw, bearerRequest(oldToken), nil); rec.Code != http.StatusOK {
+		t.Fatalf("old key status = %d", rec.Code)
+	}
+
+	// 轮换：新 kid 早于刷新周期出现，也能立即生效
+	writeJWKS(t, path, "k2", &newKey.PublicKey, time.Now())
+	newToken, _ := SignJWT(JWTAlgRS256, "k2", newKey, map[string]any{"sub": "u1", "exp": time.Now().Add(time.Minute).Unix()})
+	if rec, _ := serveAuth(t, mw, bearerRequest(newToken), nil); rec.Code != http.StatusOK {
+		t.Fatalf("rotated key status = %d", rec.Code)
+	}
+	if rec, _ := serveAuth(t, mw, bearerRequest(oldToken), nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("retired key status = %d, want 401", rec.Code)
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	store := NewRedisAPIKeyStore(client, "apikey:")
+	if err := store.Put(context.Background(), "k-redis", &Principal{Subject: "svc-b", Scopes: []string{"read"}}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(mr.Keys()) != 1 || mr.Exists("apikey:k-redis") {
+		t.Errorf("raw api key must not be stored: %v", mr.Keys())
+	}
+
+	mw := Auth(
+		NewAPIKeyAuthenticator(StaticAPIKeys{"k-static": {Subject: "svc-a"}}, ""),
+		NewAPIKeyAuthenticator(store, "X-Service-Key"),
+	)
+
+	for header, want := range map[[2]string]string{
+		{HeaderAPIKey, "k-static"}:   "svc-a",
+		{"X-Service-Key", "k-redis"}: "svc-b",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		req.Header.Set(header[0], header[1])
+		rec, p := serveAuth(t, mw, req, nil)
+		if rec.Code != http.StatusOK || p.Subject != want || p.Scheme != AuthSchemeAPIKey {
+			t.Errorf("%v: status = %d, principal = %+v", header, rec.Code, p)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.Header.Set(HeaderAPIKey, "unknown")
+	if rec, _ := serveAuth(t, mw, req, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unknown key status = %d, want 401", rec.Code)
+	}
+
+	if err := store.Delete(context.Background(), "k-redis"); err != nil {
+		t.Fatal(err)
+	}
+	req = httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.Header.Set("X-Service-Key", "k-redis")
+	if rec, _ := serveAuth(t, mw, req, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("revoked key status = %d, want 401", rec.Code)
+	}
+}
+
+func TestHMAC(t *testing.T) {
+	secret := []byte("hmac-secret")
+	mw := Auth(NewHMACAuthenticator(HMACConfig{
+		Secrets: StaticHMACSecrets{"partner": secret},
+		Nonces:  NewMemoryNonceStore(1024, 10*time.Minute),
+	}))
+
+	newSigned := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/orders?from=app", bytes.NewReader([]byte(body)))
+		if err := SignRequest(req, []byte(body), "partner", secret); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	req := newSigned(`{"id":1}`)
+	rec, p := serveAuth(t, mw, req, []byte(`{"id":1}`))
+	if rec.Code != http.StatusOK || p.Subject != "partner" || p.Scheme != AuthSchemeHMAC {
+		t.Fatalf("status = %d, principal = %+v", rec.Code, p)
+	}
+
+	// 重放同一个签名请求
+	if rec, _ = serveAuth(t, mw, req, []byte(`{"id":1}`)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("replayed request status = %d, want 401", rec.Code)
+	}
+
+	// 篡改 body
+	if rec, _ = serveAuth(t, mw, newSigned(`{"id":1}`), []byte(`{"id":2}`)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("tampered body status = %d, want 401", rec.Code)
+	}
+
+	// 过期时间戳
+	req = newSigned(`{}`)
+	req.Header.Set(HeaderSignatureTimestamp, fmt.Sprint(time.Now().Add(-time.Hour).Unix()))
+	if rec, _ = serveAuth(t, mw, req, []byte(`{}`)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("stale timestamp status = %d, want 401", rec.Code)
+	}
+}
+
+func TestAuth_NoCredentialsAndAuthorize(t *testing.T) {
+	secret := []byte("s3cr3t")
+	auth := Auth(NewJWTAuthenticator(JWTConfig{Keys: StaticJWTKeys{"": secret}}))
+
+	rec, _ := serveAuth(t, auth, httptest.NewRequest(http.MethodGet, "http://example.com/", nil), nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("no credentials status = %d, want 401", rec.Code)
+	}
+
+	chain := NewChain(auth, RequireScopes("admin"))
+	token, _ := SignJWT(JWTAlgHS256, "", secret, map[string]any{"sub": "u1", "scope": []string{"read"}, "exp": time.Now().Add(time.Minute).Unix()})
+	handler := chain.Then(okHandler)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(context.Background(), &responseWriter{ResponseWriter: recorder}, &Request{Request: bearerRequest(token)})
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("missing scope status = %d, want 403", recorder.Code)
+	}
+	if got := decodeResult(t, recorder.Body.Bytes()); got.ErrNO != errnoForbidden {
+		t.Errorf("envelope wrong: %+v", got)
+	}
+}
+
+func TestAuth_NilPrincipal(t *testing.T) {
+	broken := AuthenticatorFunc(func(ctx context.Context, r *Request) (*Principal, error) {
+		return nil, nil
+	})
+
+	rec, p := serveAuth(t, Auth(broken), httptest.NewRequest(http.MethodGet, "http://example.com/", nil), nil)
+	if rec.Code != http.StatusUnauthorized || p != nil {
+		t.Errorf("status = %d, want 401", rec.Code)
+	}
+}
+
+func TestMemoryNonceStore_DefaultSize(t *testing.T) {
+	store := NewMemoryNonceStore(0, time.Minute)
+	if fresh, err := store.Remember(context.Background(), "a", 0); !fresh || err != nil {
+		t.Errorf("Remember() = %v, %v", fresh, err)
+	}
+}
+
+func TestMemoryNonceStore(t *testing.T) {
+	store := NewMemoryNonceStore(2, time.Minute)
+	ctx := context.Background()
+
+	remember := func(nonce string, ttl time.Duration) (bool, error) {
+		t.Helper()
+		return store.Remember(ctx, nonce, ttl)
+	}
+	if fresh, err := remember("a", 20*time.Millisecond); !fresh || err != nil {
+		t.Fatalf("Remember(a) = %v, %v", fresh, err)
+	}
+	if fresh, _ := remember("a", 20*time.Millisecond); fresh {
+		t.Error("replayed nonce is fresh")
+	}
+	if fresh, err := remember("b", time.Minute); !fresh || err != nil {
+		t.Fatalf("Remember(b) = %v, %v", fresh, err)
+	}
+	// 满了也不遗忘未过期的 nonce
+	if _, err := remember("c", time.Minute); err == nil {
+		t.Error("Remember() on a full store should fail")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if fresh, err := remember("c", time.Minute); !fresh || err != nil {
+		t.Errorf("Remember(c) after a expired = %v, %v", fresh, err)
+	}
+	if fresh, _ := remember("b", time.Minute); fresh {
+		t.Error("live nonce b is forgotten")
+	}
+}
+
+func TestAuth_StoreErrorIs500(t *testing.T) {
+	failing := AuthenticatorFunc(func(ctx context.Context, r *Request) (*Principal, error) {
+		return nil, errors.New("redis down")
+	})
+
+	rec, _ := serveAuth(t, Auth(failing), httptest.NewRequest(http.MethodGet, "http://example.com/", nil), nil)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+}
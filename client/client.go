@@ -0,0 +1,301 @@
+// Package client implements the http client calling other kate services.
+// The trace and the deadline of the context are propagated, idempotent requests are retried
+// with backoff, and the {errno, errmsg, data} envelope is decoded.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stn81/kate"
+	"github.com/stn81/kate/log"
+	"github.com/stn81/kate/trace"
+	"github.com/stn81/kate/traceid"
+	"go.uber.org/zap"
+)
+
+// HeaderRequestTimeout carries the remaining time of the caller in milliseconds, it is applied by kate.RequestTimeout
+const HeaderRequestTimeout = kate.HeaderRequestTimeout
+
+// ErrUnexpectedResponse is returned when the response is not a kate envelope
+var ErrUnexpectedResponse = errors.New("unexpected response")
+
+// maxErrorBody is the max size of body quoted in ErrUnexpectedResponse
+const maxErrorBody = 256
+
+// Config is the client config
+type Config struct {
+	BaseURL    string            // prefix of the request path, e.g. http://user-service:8080
+	Header     http.Header       // headers added to every request
+	Timeout    time.Duration     // timeout per attempt, default 10s
+	MaxRetries int               // max retries of idempotent requests, 0 means no retry
+	MinBackoff time.Duration     // backoff before the first retry, doubled per retry, default 100ms
+	MaxBackoff time.Duration     // max backoff, default 2s
+	Transport  http.RoundTripper // default http.DefaultTransport
+}
+
+// Client is the http client, it is safe for concurrent use
+type Client struct {
+	conf Config
+	hc   *http.Client
+}
+
+// New create a client
+func New(conf *Config) *Client {
+	c := &Client{conf: *conf}
+	if c.conf.Timeout <= 0 {
+		c.conf.Timeout = 10 * time.Second
+	}
+	if c.conf.MinBackoff <= 0 {
+		c.conf.MinBackoff = 100 * time.Millisecond
+	}
+	if c.conf.MaxBackoff < c.conf.MinBackoff {
+		c.conf.MaxBackoff = max(2*time.Second, c.conf.MinBackoff)
+	}
+	c.conf.BaseURL = strings.TrimRight(c.conf.BaseURL, "/")
+	c.hc = &http.Client{Transport: c.conf.Transport}
+	return c
+}
+
+// Get call GET path with query, the envelope data is decoded into data
+func (c *Client) Get(ctx context.Context, path string, query url.Values, data any) error {
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return c.Call(ctx, http.MethodGet, path, nil, data)
+}
+
+// Post call POST path with the JSON encoded body
+func (c *Client) Post(ctx context.Context, path string, body, data any) error {
+	return c.Call(ctx, http.MethodPost, path, body, data)
+}
+
+// Put call PUT path with the JSON encoded body
+func (c *Client) Put(ctx context.Context, path string, body, data any) error {
+	return c.Call(ctx, http.MethodPut, path, body, data)
+}
+
+// Delete call DELETE path
+func (c *Client) Delete(ctx context.Context, path string, data any) error {
+	return c.Call(ctx, http.MethodDelete, path, nil, data)
+}
+
+// Call send the JSON encoded body (if not nil) and decode the envelope data into data (if not nil).
+// A non-zero errno is returned as kate.ErrorInfo carrying the errno, errmsg and the http status,
+// the envelope data is kept as json.RawMessage in kate.ErrorInfoWithData.
+func (c *Client) Call(ctx context.Context, method, path string, body, data any) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode request body: %w", err)
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.conf.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", kate.MIMEApplicationJSON)
+	if body != nil {
+		req.Header.Set(kate.HeaderContentType, kate.MIMEApplicationJSONCharsetUTF8)
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	return decodeResult(resp, data)
+}
+
+// Do send the request, the request context is used for tracing, deadline and logging.
+// Idempotent requests (by method or with an Idempotency-Key header) are retried on network errors
+// and 502/503/504, a request body is replayed through Request.GetBody.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx, span := trace.StartSpan(req.Context(), "HTTP "+req.Method,
+		trace.WithKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			trace.String("http.request.method", req.Method),
+			trace.String("url.full", req.URL.String()),
+		),
+	)
+	defer span.End()
+
+	// 默认头和 trace 头加在副本上，不改调用方的请求
+	req = req.Clone(req.Context())
+	for k, values := range c.conf.Header {
+		if req.Header.Get(k) == "" {
+			req.Header[k] = values
+		}
+	}
+	traceid.Inject(ctx, req.Header)
+
+	var (
+		logger   = log.GetLogger(ctx).With(zap.String("method", req.Method), zap.Stringer("url", req.URL))
+		retries  = 0
+		canRetry = isIdempotent(req) && (req.Body == nil || req.GetBody != nil)
+	)
+	if canRetry {
+		retries = c.conf.MaxRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		resp, err := c.attempt(ctx, req, attempt)
+		elapsed := time.Since(start)
+
+		if err == nil && (attempt >= retries || !isRetryableStatus(resp.StatusCode)) {
+			logger.Debug("http call",
+				zap.Int("status", resp.StatusCode), zap.Int("attempt", attempt+1), zap.Duration("elapsed", elapsed))
+			span.SetAttributes(trace.Int("http.response.status_code", resp.StatusCode))
+			if resp.StatusCode >= http.StatusInternalServerError {
+				span.SetStatus(trace.StatusError, resp.Status)
+			}
+			return resp, nil
+		}
+
+		if err != nil && (attempt >= retries || ctx.Err() != nil) {
+			logger.Error("http call failed", zap.Int("attempt", attempt+1), zap.Duration("elapsed", elapsed), zap.Error(err))
+			span.RecordError(err)
+			return nil, err
+		}
+
+		backoff := c.backoff(attempt)
+		if err != nil {
+			logger.Warn("http call failed, retrying", zap.Int("attempt", attempt+1), zap.Duration("backoff", backoff), zap.Error(err))
+		} else {
+			logger.Warn("http call failed, retrying", zap.Int("attempt", attempt+1), zap.Duration("backoff", backoff), zap.Int("status", resp.StatusCode))
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			_ = resp.Body.Close()
+		}
+		span.AddEvent("retry", trace.Int("attempt", attempt+1))
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			span.RecordError(ctx.Err())
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, req *http.Request, attempt int) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.conf.Timeout)
+
+	r := req.Clone(ctx)
+	if attempt > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		r.Body = body
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		r.Header.Set(HeaderRequestTimeout, strconv.FormatInt(time.Until(deadline).Milliseconds(), 10))
+	}
+
+	resp, err := c.hc.Do(r)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// backoff return the delay before retry attempt+1, the exponential backoff with equal jitter
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.conf.MinBackoff << min(attempt, 16)
+	if d <= 0 || d > c.conf.MaxBackoff {
+		d = c.conf.MaxBackoff
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get(kate.HeaderIdempotencyKey) != ""
+}
+
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// decodeResult decode the envelope of resp
+func decodeResult(resp *http.Response, data any) error {
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response body: %w", err)
+	}
+
+	var result struct {
+		ErrNO  *int            `json:"errno"`
+		ErrMsg string          `json:"errmsg"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err = json.Unmarshal(b, &result); err != nil || result.ErrNO == nil {
+		if len(b) > maxErrorBody {
+			b = b[:maxErrorBody]
+		}
+		return fmt.Errorf("%w: %s: %q", ErrUnexpectedResponse, resp.Status, b)
+	}
+
+	if *result.ErrNO != 0 {
+		var errInfo kate.ErrorInfo
+		if len(result.Data) > 0 && string(result.Data) != "null" {
+			errInfo = kate.NewErrorWithData(*result.ErrNO, result.ErrMsg, result.Data)
+		} else {
+			errInfo = kate.NewError(*result.ErrNO, result.ErrMsg)
+		}
+		// 2xx 里的错误 errno 不带状态码，转发时由 errno 决定
+		if resp.StatusCode >= http.StatusBadRequest {
+			return kate.WithHTTPStatus(errInfo, resp.StatusCode)
+		}
+		return errInfo
+	}
+
+	if data == nil || len(result.Data) == 0 {
+		return nil
+	}
+	if err = json.Unmarshal(result.Data, data); err != nil {
+		return fmt.Errorf("decode response data: %w", err)
+	}
+	return nil
+}
+
+// cancelBody release the attempt context after the body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
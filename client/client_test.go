@@ -0,0 +1,184 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stn81/kate"
+	"github.com/stn81/kate/traceid"
+)
+
+func newTestClient(url string) *Client {
+	return New(&Config{
+		BaseURL:    url,
+		MaxRetries: 2,
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 2 * time.Millisecond,
+	})
+}
+
+func TestCall_DecodeData(t *testing.T) {
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		_, _ = w.Write([]byte(`{"errno":0,"errmsg":"成功","data":{"id":7,"name":"kate"}}`))
+	}))
+	defer srv.Close()
+
+	remote, _ := traceid.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := traceid.WithSpanContext(traceid.ToContext(context.Background(), remote.TraceId), remote)
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	var user struct {
+		Id   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := newTestClient(srv.URL).Get(ctx, "/users/7", nil, &user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Id != 7 || user.Name != "kate" {
+		t.Errorf("unexpected data: %+v", user)
+	}
+
+	sc, err := traceid.ParseTraceparent(header.Get(traceid.HeaderTraceparent))
+	if err != nil || sc.TraceId != remote.TraceId || sc.SpanId == remote.SpanId {
+		t.Errorf("traceparent = %q", header.Get(traceid.HeaderTraceparent))
+	}
+	if header.Get(traceid.HeaderTraceId) != remote.TraceId {
+		t.Errorf("X-Trace-Id = %q", header.Get(traceid.HeaderTraceId))
+	}
+	if ms, _ := strconv.Atoi(header.Get(HeaderRequestTimeout)); ms <= 0 || ms > 10000 {
+		t.Errorf("X-Request-Timeout = %q, want capped by the attempt timeout", header.Get(HeaderRequestTimeout))
+	}
+}
+
+func TestCall_ErrorInfo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"errno":10004,"errmsg":"用户不存在","data":{"id":7}}`))
+	}))
+	defer srv.Close()
+
+	err := newTestClient(srv.URL).Get(context.Background(), "/users/7", nil, nil)
+
+	var errInfo kate.ErrorInfo
+	if !errors.As(err, &errInfo) || errInfo.Code() != 10004 || errInfo.Error() != "用户不存在" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var carrier kate.HTTPStatusCarrier
+	if !errors.As(err, &carrier) || carrier.HTTPStatus() != http.StatusNotFound {
+		t.Errorf("http status not preserved: %v", err)
+	}
+	var withData kate.ErrorInfoWithData
+	if !errors.As(err, &withData) || string(withData.Data().(json.RawMessage)) != `{"id":7}` {
+		t.Errorf("data not preserved: %v", err)
+	}
+}
+
+func TestCall_ErrorInfoWithOK(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"errno":10004,"errmsg":"用户不存在"}`))
+	}))
+	defer srv.Close()
+
+	err := newTestClient(srv.URL).Get(context.Background(), "/users/7", nil, nil)
+
+	var errInfo kate.ErrorInfo
+	if !errors.As(err, &errInfo) || errInfo.Code() != 10004 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var carrier kate.HTTPStatusCarrier
+	if errors.As(err, &carrier) {
+		t.Errorf("http status %d attached to the errno of a 200 response", carrier.HTTPStatus())
+	}
+}
+
+func TestCall_UnexpectedResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("<html>bad request</html>"))
+	}))
+	defer srv.Close()
+
+	err := newTestClient(srv.URL).Get(context.Background(), "/", nil, nil)
+	if !errors.Is(err, ErrUnexpectedResponse) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCall_RetryIdempotent(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"n":1}` {
+			t.Errorf("body not replayed: %q", body)
+		}
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"errno":0,"errmsg":"成功"}`))
+	}))
+	defer srv.Close()
+
+	if err := newTestClient(srv.URL).Put(context.Background(), "/n", map[string]int{"n": 1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("calls = %d, want 3", calls.Load())
+	}
+}
+
+func TestCall_NoRetryPost(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"errno":-3,"errmsg":"服务繁忙，请稍后重试"}`))
+	}))
+	defer srv.Close()
+
+	err := newTestClient(srv.URL).Post(context.Background(), "/orders", map[string]int{"n": 1}, nil)
+	if errInfo, ok := err.(kate.ErrorInfo); !ok || errInfo.Code() != -3 {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("POST retried: calls = %d", calls.Load())
+	}
+}
+
+func TestDo_RetryWithIdempotencyKey(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/orders", nil)
+	req.Header.Set(kate.HeaderIdempotencyKey, "k1")
+
+	resp, err := newTestClient("").Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || calls.Load() != 2 {
+		t.Errorf("status = %d, calls = %d", resp.StatusCode, calls.Load())
+	}
+	if len(req.Header) != 1 {
+		t.Errorf("request header of the caller is modified: %v", req.Header)
+	}
+}
@@ -14,7 +14,7 @@ import (
 var componentFiles = map[string][]string{
 	"grpc":  {"grpcsrv/", "config/grpc.go"},
 	"mysql": {"model/", "config/db.go"},
-	"redis": {"config/redis.go"},
+	"redis": {"config/redis.go", "config/redis_test.go"},
 }
 
 // validComponents 供 flag 校验与测试矩阵使用。
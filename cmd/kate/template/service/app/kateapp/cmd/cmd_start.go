@@ -78,7 +78,12 @@ func startCmdFunc(_ *cobra.Command, _ []string) {
 	logger.Info("server starting")
 
 	//kate:begin redis
+	config.Redis.Logger = logger
 	rdb.Init(config.Redis.Config)
+	for name, conf := range config.Redis.Instances {
+		conf.Logger = logger
+		rdb.InitNamed(name, conf)
+	}
 	defer rdb.Uninit()
 	//kate:end redis
 
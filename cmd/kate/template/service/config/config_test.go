@@ -19,6 +19,9 @@ func TestLoadDevIni(t *testing.T) {
 	if HTTP.MaxBodyBytes != 16777216 {
 		t.Errorf("max_body_bytes = %d, want 16M", HTTP.MaxBodyBytes)
 	}
+	if len(HTTP.CORS.AllowOrigins) != 1 || HTTP.CORS.AllowOrigins[0] != "*" || HTTP.CORS.AllowCredentials {
+		t.Errorf("cors = %+v, want * without credentials", HTTP.CORS)
+	}
 	if !Profiling.Enabled || Profiling.Port != 18000 {
 		t.Errorf("profiling = %+v, want enabled/18000", Profiling)
 	}
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"strings"
 	"time"
 
+	"github.com/stn81/kate"
 	"github.com/stn81/kate/app"
 	"gopkg.in/ini.v1"
 )
@@ -20,6 +22,14 @@ type HTTPConfig struct {
 	LogFile        string
 	LogSampler     LogSamplerConfig
 	HandleTimeout  time.Duration
+	CORS           CORSConfig
+}
+
+// CORSConfig defines the CORS config
+type CORSConfig struct {
+	AllowOrigins     []string
+	AllowCredentials bool
+	MaxAge           int
 }
 
 // SectionName implements the `Config.SectionName()` method
@@ -40,5 +50,10 @@ func (conf *HTTPConfig) Load(section *ini.Section) error {
 	conf.LogSampler.First = section.Key("log_sampler_first").MustInt(100)
 	conf.LogSampler.ThereAfter = section.Key("log_sampler_thereafter").MustInt(10000)
 	conf.HandleTimeout = section.Key("handle_timeout").MustDuration(30 * time.Second)
-	return nil
+	conf.CORS.AllowOrigins = strings.Split(section.Key("cors_allow_origins").MustString("*"), ",")
+	conf.CORS.AllowCredentials = section.Key("cors_allow_credentials").MustBool(false)
+	conf.CORS.MaxAge = section.Key("cors_max_age").MustInt(86400)
+	// 启动时报配置错误，而不是在构造中间件时 panic
+	cors := kate.CORSConfig{AllowOrigins: conf.CORS.AllowOrigins, AllowCredentials: conf.CORS.AllowCredentials}
+	return cors.Validate()
 }
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestHTTPConfig_CORSCredentialsWithWildcard(t *testing.T) {
+	iniFile, err := ini.Load([]byte(`
+[http]
+cors_allow_origins = "*"
+cors_allow_credentials = true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &HTTPConfig{}
+	if err = conf.Load(iniFile.Section(conf.SectionName())); err == nil {
+		t.Error("Load() = nil for cors_allow_credentials with * origin")
+	}
+}
@@ -14,6 +14,8 @@ var Redis = &RedisConfig{Config: &rdb.Config{}}
 // RedisConfig defines the redis config
 type RedisConfig struct {
 	*rdb.Config
+	// Instances 是 [redis.xxx] 子段定义的具名实例，key 为 xxx；子段未配置的 key 继承 [redis]。
+	Instances map[string]*rdb.Config
 }
 
 // SectionName implements the `Config.SectionName()` method
@@ -23,9 +25,26 @@ func (conf *RedisConfig) SectionName() string {
 
 // Load implements the `Config.Load()` method
 func (conf *RedisConfig) Load(section *ini.Section) error {
+	loadRedisConfig(conf.Config, section)
+
+	conf.Instances = make(map[string]*rdb.Config)
+	for _, child := range section.ChildSections() {
+		name := strings.TrimPrefix(child.Name(), conf.SectionName()+".")
+		instance := &rdb.Config{}
+		loadRedisConfig(instance, child)
+		conf.Instances[name] = instance
+	}
+	return nil
+}
+
+func loadRedisConfig(conf *rdb.Config, section *ini.Section) {
 	addrs := section.Key("addrs").MustString("127.0.0.1:6379")
 	conf.Addrs = strings.Split(addrs, ",")
 	conf.ClusterEnabled = section.Key("cluster_enabled").MustBool(true)
+	conf.SentinelEnabled = section.Key("sentinel_enabled").MustBool(false)
+	conf.MasterName = section.Key("master_name").MustString("mymaster")
+	conf.SentinelUsername = section.Key("sentinel_username").String()
+	conf.SentinelPassword = section.Key("sentinel_password").String()
 	conf.RouteMode = section.Key("route_mode").MustString("master_slave_random")
 	conf.MaxRedirects = section.Key("max_redirects").MustInt(8)
 	conf.MaxRetries = section.Key("max_retries").MustInt(0)
@@ -41,6 +60,7 @@ func (conf *RedisConfig) Load(section *ini.Section) error {
 	conf.MaxActiveConns = section.Key("max_active_conns").MustInt(64)
 	conf.ConnMaxIdleTime = section.Key("conn_max_idle_time").MustDuration(0)
 	conf.ConnMaxLifetime = section.Key("conn_max_life_time").MustDuration(0)
-
-	return nil
+	conf.SlowThreshold = section.Key("slow_threshold").MustDuration(0)
+	conf.MetricsEnabled = section.Key("metrics_enabled").MustBool(true)
+	conf.TracingEnabled = section.Key("tracing_enabled").MustBool(false)
 }
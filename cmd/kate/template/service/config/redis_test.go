@@ -0,0 +1,58 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stn81/kate/rdb"
+	"gopkg.in/ini.v1"
+)
+
+func TestRedisConfig_NamedInstances(t *testing.T) {
+	iniFile, err := ini.Load([]byte(`
+[redis]
+addrs = "127.0.0.1:6379"
+cluster_enabled = false
+read_timeout = 50ms
+
+[redis.session]
+addrs = "127.0.0.1:6380,127.0.0.1:6381"
+cluster_enabled = true
+
+[redis.queue]
+addrs = "127.0.0.1:26379"
+sentinel_enabled = true
+master_name = "queue"
+sentinel_password = "secret"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &RedisConfig{Config: &rdb.Config{}}
+	if err = conf.Load(iniFile.Section(conf.SectionName())); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.Addrs[0] != "127.0.0.1:6379" || conf.ClusterEnabled {
+		t.Errorf("default = %+v", conf.Config)
+	}
+	session := conf.Instances["session"]
+	if session == nil || len(conf.Instances) != 2 {
+		t.Fatalf("instances = %v", conf.Instances)
+	}
+	if len(session.Addrs) != 2 || !session.ClusterEnabled {
+		t.Errorf("session = %+v", session)
+	}
+	if session.ReadTimeout != 50*time.Millisecond {
+		t.Errorf("session read_timeout = %v, want inherited 50ms", session.ReadTimeout)
+	}
+	if session.SentinelEnabled {
+		t.Errorf("session should not be in sentinel mode")
+	}
+
+	queue := conf.Instances["queue"]
+	if !queue.SentinelEnabled || queue.MasterName != "queue" || queue.SentinelPassword != "secret" {
+		t.Errorf("queue = %+v", queue)
+	}
+}
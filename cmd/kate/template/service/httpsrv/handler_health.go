@@ -2,6 +2,9 @@ package httpsrv
 
 import (
 	"context"
+	//kate:begin redis
+	"fmt"
+	//kate:end redis
 	"net/http"
 
 	"github.com/stn81/kate"
@@ -40,11 +43,17 @@ func (h *ReadinessHandler) ServeHTTP(ctx context.Context, w kate.ResponseWriter,
 	}
 	//kate:end mysql
 	//kate:begin redis
-	if c := rdb.Get(); c != nil {
+	var redisErr error
+	rdb.Range(func(name string, c rdb.Client) bool {
 		if err := c.Ping(ctx).Err(); err != nil {
-			h.fail(ctx, w, "redis", err)
-			return
+			redisErr = fmt.Errorf("%s: %w", name, err)
+			return false
 		}
+		return true
+	})
+	if redisErr != nil {
+		h.fail(ctx, w, "redis", redisErr)
+		return
 	}
 	//kate:end redis
 	h.OkData(ctx, w, map[string]string{"status": "ok"})
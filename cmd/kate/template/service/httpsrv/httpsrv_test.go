@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stn81/kate"
@@ -14,7 +15,7 @@ import (
 	"github.com/stn81/kate/cmd/kate/template/service/config"
 )
 
-// 全链路打 /hello：真实路由 + 中间件链（TraceId/Logging/Recovery/Timeout/CORS），
+// 全链路打 /hello：真实路由 + 中间件链（TraceId/Logging/Recovery/CORS/Timeout），
 // 防"能编译但跑不起来"。不依赖任何可选组件，裁剪后仍成立。
 func TestHelloRoute(t *testing.T) {
 	if err := config.Load("../scripts/conf/dev.ini"); err != nil {
@@ -58,4 +59,35 @@ func TestHelloRoute(t *testing.T) {
 			t.Errorf("%s status = %d, want 200", path, probe.StatusCode)
 		}
 	}
+
+	// /hello 的请求已按路由模式计入指标
+	resp, err = http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if !strings.Contains(string(body), `http_requests_total{method="GET",route="/hello",status="200",errno="0"}`) {
+		t.Errorf("/metrics missing /hello request:\n%s", body)
+	}
+
+	// CORS 预检由中间件直接应答；未注册路径不应答 OPTIONS。
+	for path, want := range map[string]int{"/hello": http.StatusNoContent, "/nope": http.StatusNotFound} {
+		req, _ := http.NewRequest(http.MethodOptions, srv.URL+path, nil)
+		req.Header.Set("Origin", "https://example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		preflight, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("OPTIONS %s: %v", path, err)
+		}
+		_ = preflight.Body.Close()
+		if preflight.StatusCode != want {
+			t.Errorf("OPTIONS %s status = %d, want %d", path, preflight.StatusCode, want)
+		}
+		// 预检按路由器的 Allow 头应答，/hello 只注册了 GET
+		if methods := preflight.Header.Get("Access-Control-Allow-Methods"); want == http.StatusNoContent &&
+			(!strings.Contains(methods, http.MethodGet) || strings.Contains(methods, http.MethodPost)) {
+			t.Errorf("OPTIONS %s Access-Control-Allow-Methods = %q, want the methods of the route", path, methods)
+		}
+	}
 }
@@ -5,33 +5,49 @@ import (
 	"net/http"
 
 	"github.com/stn81/kate"
+	"github.com/stn81/kate/metrics"
 )
 
-// OptionsHandler 统一应答 CORS 预检请求（与 CORS 中间件配合）。
+// OptionsHandler 应答非 CORS 的 OPTIONS 请求（Allow 头已由路由器写好）；
+// CORS 预检由 CORS 中间件直接应答，不会走到这里。
 type OptionsHandler struct {
 	kate.BaseHandler
 }
 
 func (h *OptionsHandler) ServeHTTP(ctx context.Context, w kate.ResponseWriter, r *kate.Request) {
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // setupRoutes 注册所有路由。新增接口在此追加。
 func (s *httpService) setupRoutes(router *kate.RESTRouter) {
-	// cBase 是基础中间件链：TraceId / Logging / Recovery / Timeout / CORS。
+	// cBase 是基础中间件链：TraceId / Tracing / Metrics / Logging / Recovery / CORS / RequestTimeout / Timeout。
+	// CORS 在 Timeout 外层：Timeout 的缓冲 writer 看不到路由器写好的 Allow 头，预检需要它。
+	// RequestTimeout 按调用方 X-Request-Timeout 缩短 deadline，调用方放弃后不再白做。
+	// Metrics 在 Recovery 外层，panic 渲染出的 500 也能被计数。
+	// Tracing 为每个请求开启 server span，未调用 trace.SetExporter 时 span 不会导出。
 	// 业务可在其上 Append 自己的中间件（如鉴权），生成新的链复用。
 	cBase := kate.NewChain(
 		kate.TraceId,
+		kate.Tracing,
+		kate.Metrics(metrics.Default),
 		kate.Logging(s.accessLogger),
 		kate.Recovery,
-		kate.Timeout(s.conf.HandleTimeout),
-		kate.CORS(86400),
+		kate.CORSWithConfig(kate.CORSConfig{
+			AllowOrigins:     s.conf.CORS.AllowOrigins,
+			AllowCredentials: s.conf.CORS.AllowCredentials,
+			MaxAge:           s.conf.CORS.MaxAge,
+		}),
+		kate.RequestTimeout,
+		kate.TimeoutWithResponse(s.conf.HandleTimeout, 0),
 	)
 
-	router.OPTIONS("/*path", cBase.Then(&OptionsHandler{}))
+	// 只对已注册的路径应答 OPTIONS（路由器自动生成 Allow 头），未注册路径仍 404。
+	router.GlobalOPTIONS(cBase.Then(&OptionsHandler{}))
 	// k8s 探针（HTTP 状态码语义）：livez 恒 200（不查依赖，liveness 失败会触发重启）；
 	// readyz 逐项 ping 依赖，任一失败真实 503（摘流量不重启）。
 	router.GET("/livez", cBase.Then(&LivenessHandler{}))
 	router.GET("/readyz", cBase.Then(&ReadinessHandler{}))
+	// Prometheus 文本格式指标，不走中间件链（抓取本身不计入请求指标）。
+	router.Handler(http.MethodGet, "/metrics", metrics.Default.Handler())
 	router.GET("/hello", cBase.Then(&HelloHandler{}))
 }
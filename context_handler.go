@@ -91,6 +91,7 @@ func StdHandler(ctx context.Context, h ContextHandler, maxBodyBytes int64) http.
 
 		request = &Request{
 			Request: r,
+			Route:   r.Pattern,
 		}
 
 		response = &responseWriter{
@@ -2,18 +2,40 @@ package kate
 
 import (
 	"fmt"
+	"net/http"
 )
 
 var (
-	errnoSuccess  = 0  // success
-	errnoInternal = -1 // 服务器内部错误
-	errnoBadParam = -2 // 请求参数错误
+	errnoSuccess    = 0  // success
+	errnoInternal   = -1 // 服务器内部错误
+	errnoBadParam   = -2 // 请求参数错误
+	errnoOverloaded = -3 // 服务过载
+	errnoTimeout    = -4 // 请求处理超时
+	errnoInFlight   = -5 // 同一幂等键的请求正在处理
+	errnoMismatch   = -6 // 同一幂等键的请求内容不一致
+	errnoUnauthed   = -7 // 未认证
+	errnoForbidden  = -8 // 无权限
+	errnoLeader     = -9 // leader 状态不可用
 )
 
 var (
 	// ErrSuccess indicates api success
 	ErrSuccess        = NewError(errnoSuccess, "成功")
 	ErrServerInternal = NewError(errnoInternal, "服务器内部错误")
+	// ErrOverloaded indicates the request is shed by the concurrency limiter
+	ErrOverloaded = NewHTTPError(http.StatusServiceUnavailable, errnoOverloaded, "服务繁忙，请稍后重试")
+	// ErrTimeout indicates the request is not finished before the deadline
+	ErrTimeout = NewHTTPError(http.StatusServiceUnavailable, errnoTimeout, "请求处理超时")
+	// ErrIdempotencyInFlight indicates a request with the same idempotency key is being processed
+	ErrIdempotencyInFlight = NewHTTPError(http.StatusConflict, errnoInFlight, "相同幂等键的请求正在处理中")
+	// ErrIdempotencyMismatch indicates the idempotency key is reused with a different request
+	ErrIdempotencyMismatch = NewHTTPError(http.StatusUnprocessableEntity, errnoMismatch, "幂等键已被不同的请求使用")
+	// ErrUnauthorized indicates the request is not authenticated
+	ErrUnauthorized = NewHTTPError(http.StatusUnauthorized, errnoUnauthed, "未认证或认证已失效")
+	// ErrForbidden indicates the principal is not allowed to access
+	ErrForbidden = NewHTTPError(http.StatusForbidden, errnoForbidden, "无权限访问")
+	// ErrLeaderUnavailable indicates the leader status can't be read from the store
+	ErrLeaderUnavailable = NewHTTPError(http.StatusServiceUnavailable, errnoLeader, "leader 状态暂不可用")
 )
 
 // ErrBadParam returns an instance of bad param ErrorInfo.
@@ -3,6 +3,7 @@ module github.com/stn81/kate
 go 1.26.3
 
 require (
+	github.com/alicebob/miniredis/v2 v2.39.0
 	github.com/cloudflare/tableflip v1.2.3
 	github.com/davecgh/go-spew v1.1.1
 	github.com/go-sql-driver/mysql v1.10.0
@@ -30,6 +31,7 @@ require (
 	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/spf13/pflag v1.0.9 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	golang.org/x/net v0.55.0 // indirect
 	golang.org/x/sys v0.45.0 // indirect
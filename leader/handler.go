@@ -0,0 +1,27 @@
+package leader
+
+import (
+	"context"
+
+	"github.com/stn81/kate"
+	"github.com/stn81/kate/log"
+	"go.uber.org/zap"
+)
+
+// StatusHandler serves the Status of the elector, e.g. mounted at /debug/leader
+type StatusHandler struct {
+	kate.RESTHandler
+	Elector *Elector
+}
+
+// ServeHTTP implements kate.ContextHandler
+func (h *StatusHandler) ServeHTTP(ctx context.Context, w kate.ResponseWriter, r *kate.Request) {
+	status, err := h.Elector.Status(ctx)
+	if err != nil {
+		// 存储的错误只记日志，不返回给调用方
+		log.GetLogger(ctx).Error("get leader status", zap.String("leader", h.Elector.conf.Name), zap.Error(err))
+		h.Error(ctx, w, kate.ErrLeaderUnavailable)
+		return
+	}
+	h.OkData(ctx, w, status)
+}
@@ -0,0 +1,200 @@
+// Package leader elects one leader among the replicas with a lease on redis.
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stn81/kate/rdb"
+	"github.com/stn81/kate/redsync"
+	"go.uber.org/zap"
+)
+
+// Config is the config of Elector
+type Config struct {
+	Name          string                    // lease key, the replicas campaigning for the same name elect one leader
+	Identity      string                    // unique identity of the replica, default hostname-pid
+	TTL           time.Duration             // lease ttl, it is renewed every TTL/3 while leading, default 15s
+	RetryInterval time.Duration             // interval of campaigns while following, default TTL/3
+	OnElected     func(ctx context.Context) // run when elected, ctx is canceled when the leadership is lost
+	OnRevoked     func()                    // run after the leadership is lost and OnElected returned
+}
+
+// Status is the debug view of the election
+type Status struct {
+	Name     string        `json:"name"`
+	Identity string        `json:"identity"`
+	IsLeader bool          `json:"is_leader"`
+	Holder   string        `json:"holder"` // identity of the current leader, empty if vacant
+	TTL      time.Duration `json:"ttl"`    // remaining ttl of the lease
+	Since    time.Time     `json:"since"`  // start of the term if leading
+}
+
+// Elector campaigns for the leadership lease, at most one replica is the leader at a time
+// as long as the work stops when the leader context is canceled.
+type Elector struct {
+	client  rdb.Client
+	redsync *redsync.Redsync
+	conf    Config
+	logger  *zap.Logger
+
+	leader atomic.Bool
+	mu     sync.Mutex
+	term   context.Context
+	since  time.Time
+}
+
+// canceledContext is the leader context while not leading
+var canceledContext = func() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}()
+
+// New create an elector
+func New(client rdb.Client, conf *Config, logger *zap.Logger) *Elector {
+	e := &Elector{
+		client:  client,
+		redsync: redsync.New([]redsync.Pool{redsync.NewPool(client)}),
+		conf:    *conf,
+		term:    canceledContext,
+	}
+	if e.conf.Identity == "" {
+		hostname, _ := os.Hostname()
+		e.conf.Identity = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	if e.conf.TTL <= 0 {
+		e.conf.TTL = 15 * time.Second
+	}
+	if e.conf.RetryInterval <= 0 {
+		e.conf.RetryInterval = e.conf.TTL / 3
+	}
+	e.logger = logger.With(zap.String("leader", e.conf.Name), zap.String("identity", e.conf.Identity))
+	return e
+}
+
+// Run campaign until ctx is done, the lease is released on return if leading
+func (e *Elector) Run(ctx context.Context) {
+	mutex := e.redsync.NewMutex(ctx, e.conf.Name,
+		redsync.SetToken(e.conf.Identity),
+		redsync.SetExpiry(e.conf.TTL),
+		redsync.SetWatchdog(true),
+	)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		err := mutex.TryLock(ctx)
+		switch {
+		case err == nil:
+			e.lead(ctx, mutex)
+		case errors.Is(err, redsync.ErrFailed) || ctx.Err() != nil:
+		default:
+			e.logger.Warn("campaign failed", zap.Error(err))
+		}
+		timer.Reset(e.conf.RetryInterval)
+	}
+}
+
+// lead run a term until the lease is lost or ctx is done
+func (e *Elector) lead(ctx context.Context, mutex *redsync.Mutex) {
+	term := mutex.Context()
+
+	e.mu.Lock()
+	e.term, e.since = term, time.Now()
+	e.mu.Unlock()
+	e.leader.Store(true)
+	e.logger.Info("elected")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				e.logger.Error("elected callback panic", zap.Any("error", r), zap.Stack("stack"))
+			}
+		}()
+		if e.conf.OnElected != nil {
+			e.conf.OnElected(term)
+		}
+	}()
+
+	<-term.Done()
+	e.leader.Store(false)
+	if ctx.Err() != nil {
+		if err := mutex.Unlock(); err != nil {
+			e.logger.Warn("resign failed", zap.Error(err))
+		}
+		e.logger.Info("resigned")
+	} else {
+		e.logger.Warn("leadership lost", zap.NamedError("cause", context.Cause(term)))
+	}
+
+	// 等待本任期的工作退出后才能参与下一次选举，避免两个任期的工作在本进程内重叠
+	<-done
+	e.mu.Lock()
+	e.term, e.since = canceledContext, time.Time{}
+	e.mu.Unlock()
+	if e.conf.OnRevoked != nil {
+		e.conf.OnRevoked()
+	}
+}
+
+// IsLeader check whether the replica is leading
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Context return the context of the current term, it is canceled when the leadership is lost,
+// and is always canceled while not leading.
+func (e *Elector) Context() context.Context {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.term
+}
+
+// Identity return the identity of the replica
+func (e *Elector) Identity() string {
+	return e.conf.Identity
+}
+
+// Status return the status of the election, the holder is read from redis
+func (e *Elector) Status(ctx context.Context) (*Status, error) {
+	status := &Status{
+		Name:     e.conf.Name,
+		Identity: e.conf.Identity,
+		IsLeader: e.IsLeader(),
+	}
+	e.mu.Lock()
+	status.Since = e.since
+	e.mu.Unlock()
+
+	holder, err := e.client.Get(ctx, e.conf.Name).Result()
+	switch {
+	case errors.Is(err, redis.Nil):
+		return status, nil
+	case err != nil:
+		return nil, err
+	}
+	status.Holder = holder
+
+	ttl, err := e.client.PTTL(ctx, e.conf.Name).Result()
+	if err != nil {
+		return nil, err
+	}
+	status.TTL = max(ttl, 0)
+	return status, nil
+}
@@ -0,0 +1,202 @@
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stn81/kate"
+	"github.com/stn81/kate/redsync"
+	"go.uber.org/zap"
+)
+
+func newTestClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return mr, client
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+type replica struct {
+	elector *Elector
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func startReplica(client *redis.Client, conf Config) *replica {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &replica{
+		elector: New(client, &conf, zap.NewNop()),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(r.done)
+		r.elector.Run(ctx)
+	}()
+	return r
+}
+
+func (r *replica) stop() {
+	r.cancel()
+	<-r.done
+}
+
+func TestElector_Failover(t *testing.T) {
+	_, client := newTestClient(t)
+
+	var replicas []*replica
+	for _, id := range []string{"a", "b", "c"} {
+		replicas = append(replicas, startReplica(client, Config{
+			Name:          "leader",
+			Identity:      id,
+			TTL:           300 * time.Millisecond,
+			RetryInterval: 10 * time.Millisecond,
+		}))
+	}
+	defer func() {
+		for _, r := range replicas {
+			r.stop()
+		}
+	}()
+
+	leaders := func() []*replica {
+		var result []*replica
+		for _, r := range replicas {
+			if r.elector.IsLeader() {
+				result = append(result, r)
+			}
+		}
+		return result
+	}
+	waitFor(t, "a leader", func() bool { return len(leaders()) == 1 })
+
+	first := leaders()[0]
+	status, err := first.elector.Status(context.Background())
+	if err != nil || status.Holder != first.elector.Identity() || !status.IsLeader || status.TTL <= 0 {
+		t.Fatalf("Status() = %+v, %v", status, err)
+	}
+
+	first.stop()
+	if first.elector.IsLeader() || first.elector.Context().Err() == nil {
+		t.Fatal("resigned replica is still leading")
+	}
+	waitFor(t, "a new leader", func() bool {
+		l := leaders()
+		return len(l) == 1 && l[0] != first
+	})
+}
+
+func TestElector_Lost(t *testing.T) {
+	mr, client := newTestClient(t)
+
+	var (
+		mu        sync.Mutex
+		events    []string
+		termErr   error
+		revoked   = make(chan struct{})
+		closeOnce sync.Once
+	)
+	r := startReplica(client, Config{
+		Name:          "leader",
+		Identity:      "a",
+		TTL:           300 * time.Millisecond,
+		RetryInterval: time.Hour,
+		OnElected: func(ctx context.Context) {
+			mu.Lock()
+			events = append(events, "elected")
+			mu.Unlock()
+
+			<-ctx.Done()
+			mu.Lock()
+			termErr = context.Cause(ctx)
+			mu.Unlock()
+		},
+		OnRevoked: func() {
+			mu.Lock()
+			events = append(events, "revoked")
+			mu.Unlock()
+			closeOnce.Do(func() { close(revoked) })
+		},
+	})
+	defer r.stop()
+
+	waitFor(t, "the leadership", r.elector.IsLeader)
+	if err := mr.Set("leader", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-revoked:
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnRevoked is not called after the lease is taken")
+	}
+	if r.elector.IsLeader() {
+		t.Error("IsLeader() after the lease is taken")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 2 || events[0] != "elected" || events[1] != "revoked" {
+		t.Errorf("events = %v", events)
+	}
+	if !errors.Is(termErr, redsync.ErrLockLost) {
+		t.Errorf("term cause = %v, want ErrLockLost", termErr)
+	}
+}
+
+func TestElector_StatusVacant(t *testing.T) {
+	_, client := newTestClient(t)
+
+	e := New(client, &Config{Name: "leader"}, zap.NewNop())
+	status, err := e.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Holder != "" || status.IsLeader || status.Identity == "" {
+		t.Errorf("Status() = %+v", status)
+	}
+}
+
+func TestStatusHandler_Unavailable(t *testing.T) {
+	mr, client := newTestClient(t)
+	mr.SetError("READONLY redis internal detail")
+
+	router := kate.NewRESTRouter(context.Background(), zap.NewNop())
+	router.GET("/debug/leader", &StatusHandler{Elector: New(client, &Config{Name: "leader"}, zap.NewNop())})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/leader", nil))
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", recorder.Code)
+	}
+	var result kate.Result
+	if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+		t.Fatalf("body not errno envelope: %v: %s", err, recorder.Body)
+	}
+	if result.ErrNO != kate.ErrLeaderUnavailable.Code() || strings.Contains(result.ErrMsg, "redis") {
+		t.Errorf("envelope = %+v, want ErrLeaderUnavailable without the store error", result)
+	}
+}
@@ -0,0 +1,380 @@
+// Package metrics provides dependency-free counters, gauges and histograms exposed in
+// the Prometheus text exposition format (version 0.0.4).
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefBuckets is the default histogram buckets in seconds, suitable for request latency
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ExponentialBuckets return count buckets, the first is start and each is factor times the previous
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+// Default is the default registry
+var Default = NewRegistry()
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// Registry holds the metric families
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// NewRegistry create a registry
+func NewRegistry() *Registry {
+	return &Registry{
+		families: make(map[string]*family),
+	}
+}
+
+// Counter return the counter vector of name, it is created on first call.
+// It panics if name is registered with another type or labels.
+func (r *Registry) Counter(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{r.getOrCreate(name, help, typeCounter, nil, labelNames)}
+}
+
+// Gauge return the gauge vector of name, it is created on first call.
+// It panics if name is registered with another type or labels.
+func (r *Registry) Gauge(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{r.getOrCreate(name, help, typeGauge, nil, labelNames)}
+}
+
+// Histogram return the histogram vector of name, it is created on first call, nil buckets means DefBuckets.
+// It panics if name is registered with another type or labels.
+func (r *Registry) Histogram(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	return &HistogramVec{r.getOrCreate(name, help, typeHistogram, buckets, labelNames)}
+}
+
+func (r *Registry) getOrCreate(name, help, typ string, buckets []float64, labelNames []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, ok := r.families[name]; ok {
+		if f.typ != typ || strings.Join(f.labelNames, ",") != strings.Join(labelNames, ",") {
+			panic(fmt.Sprintf("metrics: %s already registered as %s%v", name, f.typ, f.labelNames))
+		}
+		return f
+	}
+
+	f := &family{
+		name:       name,
+		help:       help,
+		typ:        typ,
+		buckets:    append([]float64(nil), buckets...),
+		labelNames: append([]string(nil), labelNames...),
+		series:     make(map[string]*series),
+	}
+	sort.Float64s(f.buckets)
+	r.families[name] = f
+	return f
+}
+
+// WriteTo writes all the metrics in text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.Unlock()
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, f := range families {
+		f.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// Handler return the http handler exposing the metrics
+func (r *Registry) Handler() http.Handler {
+	f := func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = r.WriteTo(w)
+	}
+	return http.HandlerFunc(f)
+}
+
+// CounterVec is a counter partitioned by labels
+type CounterVec struct {
+	f *family
+}
+
+// WithLabelValues return the counter of the label values
+func (v *CounterVec) WithLabelValues(values ...string) *Counter {
+	return &Counter{v.f.get(values)}
+}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	s *series
+}
+
+// Inc increases the counter by 1
+func (c *Counter) Inc() {
+	c.s.value.add(1)
+}
+
+// Add increases the counter by v, v must not be negative
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.s.value.add(v)
+}
+
+// GaugeVec is a gauge partitioned by labels
+type GaugeVec struct {
+	f *family
+}
+
+// WithLabelValues return the gauge of the label values
+func (v *GaugeVec) WithLabelValues(values ...string) *Gauge {
+	return &Gauge{v.f.get(values)}
+}
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	s *series
+}
+
+// Set sets the gauge to v
+func (g *Gauge) Set(v float64) {
+	g.s.value.store(v)
+}
+
+// Add adds v to the gauge
+func (g *Gauge) Add(v float64) {
+	g.s.value.add(v)
+}
+
+// Inc increases the gauge by 1
+func (g *Gauge) Inc() {
+	g.s.value.add(1)
+}
+
+// Dec decreases the gauge by 1
+func (g *Gauge) Dec() {
+	g.s.value.add(-1)
+}
+
+// HistogramVec is a histogram partitioned by labels
+type HistogramVec struct {
+	f *family
+}
+
+// WithLabelValues return the histogram of the label values
+func (v *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	return &Histogram{v.f.get(values), v.f.buckets}
+}
+
+// Histogram samples observations into buckets
+type Histogram struct {
+	s       *series
+	buckets []float64
+}
+
+// Observe adds an observation
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	if i < len(h.buckets) {
+		atomic.AddUint64(&h.s.counts[i], 1)
+	}
+	atomic.AddUint64(&h.s.count, 1)
+	h.s.value.add(v)
+}
+
+type family struct {
+	name       string
+	help       string
+	typ        string
+	buckets    []float64
+	labelNames []string
+
+	mu     sync.RWMutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       atomicFloat // counter/gauge value, or histogram sum
+	counts      []uint64    // histogram non-cumulative bucket counts
+	count       uint64      // histogram observation count
+}
+
+func (f *family) get(values []string) *series {
+	if len(values) != len(f.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labelNames), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	f.mu.RLock()
+	s, ok := f.series[key]
+	f.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if s, ok = f.series[key]; ok {
+		return s
+	}
+	s = &series{labelValues: append([]string(nil), values...)}
+	if f.typ == typeHistogram {
+		s.counts = make([]uint64, len(f.buckets))
+	}
+	f.series[key] = s
+	return s
+}
+
+func (f *family) write(w *bufio.Writer) {
+	f.mu.RLock()
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	all := make([]*series, len(keys))
+	for i, key := range keys {
+		all[i] = f.series[key]
+	}
+	f.mu.RUnlock()
+
+	if len(all) == 0 {
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	_, _ = fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+
+	for _, s := range all {
+		if f.typ != typeHistogram {
+			writeSample(w, f.name, f.labelNames, s.labelValues, "", "", s.value.load())
+			continue
+		}
+
+		var cumulative uint64
+		for i, upper := range f.buckets {
+			cumulative += atomic.LoadUint64(&s.counts[i])
+			writeSample(w, f.name+"_bucket", f.labelNames, s.labelValues, "le", formatFloat(upper), float64(cumulative))
+		}
+		count := atomic.LoadUint64(&s.count)
+		writeSample(w, f.name+"_bucket", f.labelNames, s.labelValues, "le", "+Inf", float64(count))
+		writeSample(w, f.name+"_sum", f.labelNames, s.labelValues, "", "", s.value.load())
+		writeSample(w, f.name+"_count", f.labelNames, s.labelValues, "", "", float64(count))
+	}
+}
+
+func writeSample(w *bufio.Writer, name string, labelNames, labelValues []string, extraName, extraValue string, v float64) {
+	_, _ = w.WriteString(name)
+	if len(labelNames) > 0 || extraName != "" {
+		_ = w.WriteByte('{')
+		for i, labelName := range labelNames {
+			if i > 0 {
+				_ = w.WriteByte(',')
+			}
+			_, _ = fmt.Fprintf(w, "%s=\"%s\"", labelName, escapeLabelValue(labelValues[i]))
+		}
+		if extraName != "" {
+			if len(labelNames) > 0 {
+				_ = w.WriteByte(',')
+			}
+			_, _ = fmt.Fprintf(w, "%s=\"%s\"", extraName, extraValue)
+		}
+		_ = w.WriteByte('}')
+	}
+	_ = w.WriteByte(' ')
+	_, _ = w.WriteString(formatFloat(v))
+	_ = w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+type atomicFloat struct {
+	bits uint64
+}
+
+func (f *atomicFloat) load() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&f.bits))
+}
+
+func (f *atomicFloat) store(v float64) {
+	atomic.StoreUint64(&f.bits, math.Float64bits(v))
+}
+
+func (f *atomicFloat) add(v float64) {
+	for {
+		old := atomic.LoadUint64(&f.bits)
+		n := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(&f.bits, old, n) {
+			return
+		}
+	}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.n += int64(n)
+	return n, err
+}
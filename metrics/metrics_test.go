@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	reg := NewRegistry()
+
+	requests := reg.Counter("http_requests_total", "Total requests.", "method", "route")
+	requests.WithLabelValues("GET", "/users/:id").Inc()
+	requests.WithLabelValues("GET", "/users/:id").Add(2)
+	requests.WithLabelValues("POST", `/a"b\c`).Inc()
+
+	reg.Gauge("in_flight", "In-flight\nrequests.").WithLabelValues().Set(3)
+
+	latency := reg.Histogram("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	latency.WithLabelValues("/x").Observe(0.05)
+	latency.WithLabelValues("/x").Observe(0.5)
+	latency.WithLabelValues("/x").Observe(5)
+
+	// 未产生 series 的 family 不输出
+	reg.Counter("unused_total", "Unused.")
+
+	var sb strings.Builder
+	if _, err := reg.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP http_requests_total Total requests.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",route="/users/:id"} 3
+http_requests_total{method="POST",route="/a\"b\\c"} 1
+# HELP in_flight In-flight\nrequests.
+# TYPE in_flight gauge
+in_flight 3
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/x",le="0.1"} 1
+latency_seconds_bucket{route="/x",le="1"} 2
+latency_seconds_bucket{route="/x",le="+Inf"} 3
+latency_seconds_sum{route="/x"} 5.55
+latency_seconds_count{route="/x"} 3
+`
+	if got := sb.String(); got != want {
+		t.Errorf("exposition mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRegistry_GetOrCreate(t *testing.T) {
+	reg := NewRegistry()
+	reg.Counter("c_total", "C.", "a").WithLabelValues("1").Inc()
+	reg.Counter("c_total", "C.", "a").WithLabelValues("1").Inc()
+
+	var sb strings.Builder
+	_, _ = reg.WriteTo(&sb)
+	if !strings.Contains(sb.String(), `c_total{a="1"} 2`) {
+		t.Errorf("same name should share the series:\n%s", sb.String())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering with another type should panic")
+		}
+	}()
+	reg.Gauge("c_total", "C.", "a")
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	reg := NewRegistry()
+	reg.Counter("up_total", "Up.").WithLabelValues().Inc()
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "up_total 1\n") {
+		t.Errorf("body = %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,233 @@
+package kate
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/stn81/kate/log"
+	"go.uber.org/zap"
+)
+
+// ConcurrencyConfig defines the config of the concurrency limiting (bulkhead) middleware
+type ConcurrencyConfig struct {
+	// MaxInFlight is the max number of requests served at the same time.
+	// In adaptive mode it is the initial limit.
+	MaxInFlight int
+	// MaxQueue is the max number of requests waiting for a slot, 0 means no waiting.
+	MaxQueue int
+	// QueueTimeout is the max time a request waits in queue, 0 means wait until ctx done.
+	QueueTimeout time.Duration
+	// RetryAfter is sent as `Retry-After` header when a request is shed, 0 means not sent.
+	RetryAfter time.Duration
+
+	// Adaptive enables the AIMD limit: the limit grows by one while the limit is
+	// being used and latency stays below LatencyThreshold, and shrinks by BackoffRatio
+	// whenever a request is slower than LatencyThreshold (default 1s).
+	Adaptive         bool
+	MinLimit         int
+	MaxLimit         int
+	LatencyThreshold time.Duration
+	BackoffRatio     float64
+}
+
+// ConcurrencyStats is the snapshot of a ConcurrencyLimiter
+type ConcurrencyStats struct {
+	Limit    int
+	InFlight int
+	Queued   int
+	Accepted int64
+	Rejected int64
+}
+
+// ConcurrencyLimiter caps in-flight requests of the routes it proxies, excess requests
+// wait in a bounded queue and are shed with 503 when the queue is full or timed out.
+// Share one limiter among routes to build a bulkhead for a group.
+type ConcurrencyLimiter struct {
+	conf ConcurrencyConfig
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	waiters  []chan struct{}
+	accepted int64
+	rejected int64
+}
+
+// ConcurrencyLimit implements the concurrency limiting middleware with fixed limit
+func ConcurrencyLimit(maxInFlight, maxQueue int) *ConcurrencyLimiter {
+	return NewConcurrencyLimiter(ConcurrencyConfig{
+		MaxInFlight: maxInFlight,
+		MaxQueue:    maxQueue,
+	})
+}
+
+// NewConcurrencyLimiter create a concurrency limiter
+func NewConcurrencyLimiter(conf ConcurrencyConfig) *ConcurrencyLimiter {
+	if conf.MaxInFlight <= 0 {
+		conf.MaxInFlight = 1
+	}
+	if conf.Adaptive {
+		if conf.MinLimit <= 0 {
+			conf.MinLimit = 1
+		}
+		if conf.MaxLimit < conf.MaxInFlight {
+			conf.MaxLimit = conf.MaxInFlight
+		}
+		if conf.BackoffRatio <= 0 || conf.BackoffRatio >= 1 {
+			conf.BackoffRatio = 0.9
+		}
+		// 没有阈值 limit 只增不减
+		if conf.LatencyThreshold <= 0 {
+			conf.LatencyThreshold = time.Second
+		}
+	}
+	return &ConcurrencyLimiter{
+		conf:  conf,
+		limit: float64(conf.MaxInFlight),
+	}
+}
+
+// Proxy implements the Middleware interface
+func (l *ConcurrencyLimiter) Proxy(h ContextHandler) ContextHandler {
+	f := func(ctx context.Context, w ResponseWriter, r *Request) {
+		inFlight, ok := l.acquire(ctx)
+		if !ok {
+			log.GetLogger(ctx).Warn("request shed by concurrency limiter",
+				zap.Int("limit", l.Limit()),
+				zap.Int("in_flight", inFlight))
+
+			if l.conf.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(l.conf.RetryAfter.Seconds()))))
+			}
+			writeError(ctx, w, ErrOverloaded)
+			return
+		}
+
+		start := time.Now()
+		defer func() {
+			l.release(time.Since(start))
+		}()
+
+		h.ServeHTTP(ctx, w, r)
+	}
+	return ContextHandlerFunc(f)
+}
+
+// Limit return the current limit
+func (l *ConcurrencyLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// Stats return the snapshot of the limiter
+func (l *ConcurrencyLimiter) Stats() ConcurrencyStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return ConcurrencyStats{
+		Limit:    int(l.limit),
+		InFlight: l.inFlight,
+		Queued:   len(l.waiters),
+		Accepted: l.accepted,
+		Rejected: l.rejected,
+	}
+}
+
+// acquire takes a slot, it returns the in-flight count when the slot is taken
+func (l *ConcurrencyLimiter) acquire(ctx context.Context) (int, bool) {
+	l.mu.Lock()
+	if l.inFlight < int(l.limit) && len(l.waiters) == 0 {
+		l.inFlight++
+		l.accepted++
+		inFlight := l.inFlight
+		l.mu.Unlock()
+		return inFlight, true
+	}
+	if len(l.waiters) >= l.conf.MaxQueue {
+		l.rejected++
+		inFlight := l.inFlight
+		l.mu.Unlock()
+		return inFlight, false
+	}
+	ready := make(chan struct{})
+	l.waiters = append(l.waiters, ready)
+	l.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if l.conf.QueueTimeout > 0 {
+		timer := time.NewTimer(l.conf.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-ready:
+		l.mu.Lock()
+		inFlight := l.inFlight
+		l.mu.Unlock()
+		return inFlight, true
+	case <-ctx.Done():
+	case <-timeout:
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	select {
+	case <-ready:
+		// granted while giving up, hand the slot over to the next one
+		l.inFlight--
+		l.accepted--
+		l.wakeup()
+	default:
+		l.removeWaiter(ready)
+	}
+	l.rejected++
+	return l.inFlight, false
+}
+
+func (l *ConcurrencyLimiter) release(latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// 按释放时的并发数判断 limit 是否被充分使用，获取时的并发数可能早已过时
+	if l.conf.Adaptive {
+		l.adjust(l.inFlight, latency)
+	}
+	l.inFlight--
+	l.wakeup()
+}
+
+// adjust updates the limit using AIMD: additive increase, multiplicative decrease
+func (l *ConcurrencyLimiter) adjust(inFlight int, latency time.Duration) {
+	switch {
+	case latency > l.conf.LatencyThreshold:
+		l.limit = math.Max(float64(l.conf.MinLimit), math.Floor(l.limit*l.conf.BackoffRatio))
+	case inFlight*2 >= int(l.limit):
+		l.limit = math.Min(float64(l.conf.MaxLimit), l.limit+1)
+	}
+}
+
+// wakeup grants free slots to the waiters in FIFO order, must be called with mu held
+func (l *ConcurrencyLimiter) wakeup() {
+	for l.inFlight < int(l.limit) && len(l.waiters) > 0 {
+		ready := l.waiters[0]
+		l.waiters[0] = nil
+		l.waiters = l.waiters[1:]
+		l.inFlight++
+		l.accepted++
+		close(ready)
+	}
+}
+
+func (l *ConcurrencyLimiter) removeWaiter(ready chan struct{}) {
+	for i, waiter := range l.waiters {
+		if waiter == ready {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			return
+		}
+	}
+}
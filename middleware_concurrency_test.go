@@ -0,0 +1,206 @@
+package kate
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func serveLimited(l *ConcurrencyLimiter, h ContextHandler) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	l.Proxy(h).ServeHTTP(context.Background(), &responseWriter{ResponseWriter: recorder}, &Request{Request: req})
+	return recorder
+}
+
+func TestConcurrencyLimit_ShedWhenFull(t *testing.T) {
+	var (
+		l       = ConcurrencyLimit(1, 0)
+		entered = make(chan struct{})
+		unblock = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+	blocking := ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		close(entered)
+		<-unblock
+		w.WriteHeader(http.StatusOK)
+	})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		serveLimited(l, blocking)
+	}()
+	<-entered
+
+	rec := serveLimited(l, ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		t.Error("handler should not be called when limiter is full")
+	}))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+	if got := decodeResult(t, rec.Body.Bytes()); got.ErrNO != errnoOverloaded {
+		t.Errorf("envelope wrong: %+v", got)
+	}
+
+	close(unblock)
+	wg.Wait()
+
+	stats := l.Stats()
+	if stats.InFlight != 0 || stats.Accepted != 1 || stats.Rejected != 1 {
+		t.Errorf("stats = %+v, want in_flight=0 accepted=1 rejected=1", stats)
+	}
+}
+
+func TestConcurrencyLimit_QueueTimeout(t *testing.T) {
+	var (
+		l = NewConcurrencyLimiter(ConcurrencyConfig{
+			MaxInFlight:  1,
+			MaxQueue:     1,
+			QueueTimeout: 20 * time.Millisecond,
+			RetryAfter:   time.Second,
+		})
+		entered = make(chan struct{})
+		unblock = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		serveLimited(l, ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+			close(entered)
+			<-unblock
+		}))
+	}()
+	<-entered
+
+	rec := serveLimited(l, ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {}))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+
+	close(unblock)
+	wg.Wait()
+
+	if stats := l.Stats(); stats.Queued != 0 || stats.Rejected != 1 {
+		t.Errorf("stats = %+v, want queued=0 rejected=1", stats)
+	}
+}
+
+func TestConcurrencyLimit_QueuedRequestServed(t *testing.T) {
+	var (
+		l       = ConcurrencyLimit(1, 1)
+		entered = make(chan struct{})
+		unblock = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		serveLimited(l, ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+			close(entered)
+			<-unblock
+		}))
+	}()
+	<-entered
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- serveLimited(l, ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+	}()
+
+	for l.Stats().Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(unblock)
+
+	if rec := <-done; rec.Code != http.StatusOK {
+		t.Errorf("queued request status = %d, want 200", rec.Code)
+	}
+	wg.Wait()
+}
+
+func TestConcurrencyLimit_Adaptive(t *testing.T) {
+	l := NewConcurrencyLimiter(ConcurrencyConfig{
+		MaxInFlight:      10,
+		Adaptive:         true,
+		MinLimit:         2,
+		MaxLimit:         20,
+		LatencyThreshold: 10 * time.Millisecond,
+		BackoffRatio:     0.5,
+	})
+
+	slow := ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		time.Sleep(15 * time.Millisecond)
+	})
+	serveLimited(l, slow)
+	if got := l.Limit(); got != 5 {
+		t.Errorf("limit after slow request = %d, want 5", got)
+	}
+	serveLimited(l, slow)
+	serveLimited(l, slow)
+	if got := l.Limit(); got != 2 {
+		t.Errorf("limit should not drop below MinLimit, got %d", got)
+	}
+
+	// 单个请求占满 limit 的一半即视为 limit 被充分使用，加性增长
+	fast := ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {})
+	serveLimited(l, fast)
+	if got := l.Limit(); got != 3 {
+		t.Errorf("limit after fast request = %d, want 3", got)
+	}
+}
+
+func TestConcurrencyLimit_AdaptiveDefaultThreshold(t *testing.T) {
+	l := NewConcurrencyLimiter(ConcurrencyConfig{MaxInFlight: 10, Adaptive: true})
+	if l.conf.LatencyThreshold <= 0 {
+		t.Fatalf("LatencyThreshold = %v, want a default", l.conf.LatencyThreshold)
+	}
+
+	l.inFlight = 1
+	l.release(2 * l.conf.LatencyThreshold)
+	if got := l.Limit(); got != 9 {
+		t.Errorf("limit after slow request = %d, want 9", got)
+	}
+}
+
+func TestConcurrencyLimit_AdaptiveReleaseInFlight(t *testing.T) {
+	l := NewConcurrencyLimiter(ConcurrencyConfig{
+		MaxInFlight:      4,
+		Adaptive:         true,
+		MaxLimit:         10,
+		LatencyThreshold: time.Second,
+	})
+
+	var (
+		entered = make(chan struct{})
+		release = make(chan struct{})
+		done    = make(chan struct{})
+	)
+	// 第一个请求获取时并发为 1，释放时并发为 2，按释放时计算 limit 已被充分使用
+	go func() {
+		defer close(done)
+		serveLimited(l, ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+			close(entered)
+			<-release
+		}))
+	}()
+	<-entered
+	serveLimited(l, ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		close(release)
+		<-done
+		if got := l.Limit(); got != 5 {
+			t.Errorf("limit = %d, want 5", got)
+		}
+	}))
+}
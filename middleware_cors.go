@@ -2,43 +2,201 @@ package kate
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"strconv"
+	"strings"
 )
 
-func CORS(maxAge int) Middleware {
-	mf := func(h ContextHandler) ContextHandler {
-		f := func(ctx context.Context, w ResponseWriter, r *Request) {
-			corsWriter := &corsResponseWriter{
-				ResponseWriter: w,
-				maxAge:         maxAge,
-			}
-			h.ServeHTTP(ctx, corsWriter, r)
+// DefaultCORSAllowMethods is used when neither CORSConfig.AllowMethods nor the `Allow` header is set
+var DefaultCORSAllowMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// CORSConfig defines the config of CORS middleware
+type CORSConfig struct {
+	// AllowOrigins is the origin allowlist, an item can be:
+	//   - "*": any origin
+	//   - exact origin: "https://example.com"
+	//   - wildcard subdomain: "https://*.example.com", the apex domain is not matched
+	AllowOrigins []string
+	// AllowOriginFunc is checked when AllowOrigins does not match
+	AllowOriginFunc func(origin string) bool
+	// AllowMethods defaults to the `Allow` header set by the router, or DefaultCORSAllowMethods
+	AllowMethods []string
+	// AllowHeaders defaults to echo the `Access-Control-Request-Headers` of preflight
+	AllowHeaders []string
+	// ExposeHeaders lists the response headers readable by browser scripts
+	ExposeHeaders []string
+	// AllowCredentials allows cookies and auth headers, the matched origin is echoed
+	// instead of "*" as browsers require. It requires an explicit allowlist, "*" is rejected
+	// since it would let any site make credentialed requests.
+	AllowCredentials bool
+	// MaxAge is the preflight cache time in seconds, 0 means not sent
+	MaxAge int
+}
+
+// Validate check the config, AllowCredentials requires an explicit origin allowlist.
+// Services should validate the loaded config since CORSWithConfig panics on it.
+func (conf CORSConfig) Validate() error {
+	if !conf.AllowCredentials {
+		return nil
+	}
+	for _, origin := range conf.AllowOrigins {
+		if strings.TrimSpace(origin) == "*" {
+			return errors.New(`cors: AllowCredentials requires an explicit origin allowlist instead of "*"`)
 		}
-		return ContextHandlerFunc(f)
 	}
-	return MiddlewareFunc(mf)
+	return nil
+}
+
+// CORS implements the CORS middleware allowing any origin without credentials
+func CORS(maxAge int) Middleware {
+	return CORSWithConfig(CORSConfig{
+		AllowOrigins: []string{"*"},
+		MaxAge:       maxAge,
+	})
 }
 
-type corsResponseWriter struct {
-	ResponseWriter
-	maxAge int
+// CORSWithConfig implements the CORS middleware, preflight requests are answered
+// directly without calling the handler. It panics if AllowCredentials is used with "*".
+// Mount it outside TimeoutWithResponse, whose buffered writer hides the Allow header of the router.
+func CORSWithConfig(conf CORSConfig) Middleware {
+	p := newCORSProxy(conf)
+	return MiddlewareFunc(p.proxy)
 }
 
-func (w *corsResponseWriter) WriteHeader(statusCode int) {
-	w.setCORSHeaders()
-	w.ResponseWriter.WriteHeader(statusCode)
+type corsProxy struct {
+	conf          CORSConfig
+	allowAll      bool
+	origins       map[string]bool
+	wildcards     [][2]string
+	allowMethods  string
+	allowHeaders  string
+	exposeHeaders string
+	maxAge        string
 }
 
-func (w *corsResponseWriter) Write(b []byte) (int, error) {
-	w.setCORSHeaders()
-	return w.ResponseWriter.Write(b)
+func newCORSProxy(conf CORSConfig) *corsProxy {
+	p := &corsProxy{
+		conf:          conf,
+		origins:       make(map[string]bool),
+		allowMethods:  strings.Join(conf.AllowMethods, ", "),
+		allowHeaders:  strings.Join(conf.AllowHeaders, ", "),
+		exposeHeaders: strings.Join(conf.ExposeHeaders, ", "),
+	}
+	if conf.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(conf.MaxAge)
+	}
+
+	for _, origin := range conf.AllowOrigins {
+		origin = strings.ToLower(strings.TrimSpace(origin))
+		switch {
+		case origin == "*":
+			p.allowAll = true
+		case strings.Contains(origin, "://*."):
+			i := strings.Index(origin, "*")
+			p.wildcards = append(p.wildcards, [2]string{origin[:i], origin[i+1:]})
+		case origin != "":
+			p.origins[origin] = true
+		}
+	}
+	if err := conf.Validate(); err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func (p *corsProxy) proxy(h ContextHandler) ContextHandler {
+	f := func(ctx context.Context, w ResponseWriter, r *Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			h.ServeHTTP(ctx, w, r)
+			return
+		}
+
+		header := w.Header()
+		if !p.allowAll {
+			header.Add("Vary", "Origin")
+		}
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if !p.isOriginAllowed(origin) {
+			if preflight {
+				writeError(ctx, w, ErrForbidden)
+				return
+			}
+			h.ServeHTTP(ctx, w, r)
+			return
+		}
+
+		if p.allowAll {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if p.conf.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if p.exposeHeaders != "" {
+				header.Set("Access-Control-Expose-Headers", p.exposeHeaders)
+			}
+			h.ServeHTTP(ctx, w, r)
+			return
+		}
+
+		allowMethods := p.allowMethods
+		if allowMethods == "" {
+			// httprouter 自动应答 OPTIONS 时已在 Allow 头写好该路径注册的方法
+			if allowMethods = header.Get("Allow"); allowMethods == "" {
+				allowMethods = strings.Join(DefaultCORSAllowMethods, ", ")
+			}
+		}
+		header.Set("Access-Control-Allow-Methods", allowMethods)
+
+		allowHeaders := p.allowHeaders
+		if allowHeaders == "" {
+			allowHeaders = r.Header.Get("Access-Control-Request-Headers")
+		}
+		if allowHeaders != "" {
+			header.Set("Access-Control-Allow-Headers", allowHeaders)
+		}
+		if p.maxAge != "" {
+			header.Set("Access-Control-Max-Age", p.maxAge)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+	return ContextHandlerFunc(f)
 }
 
-func (w *corsResponseWriter) setCORSHeaders() {
-	h := w.Header()
-	h.Set("Access-Control-Allow-Origin", "*")
-	h.Set("Access-Control-Allow-Methods", h.Get("Allow"))
-	h.Set("Access-Control-Allow-Headers", "*")
-	h.Set("Access-Control-Allow-Credentials", "true")
-	h.Set("Access-Control-Max-Age", strconv.Itoa(w.maxAge))
+func (p *corsProxy) isOriginAllowed(origin string) bool {
+	if p.allowAll {
+		return true
+	}
+
+	lower := strings.ToLower(origin)
+	if p.origins[lower] {
+		return true
+	}
+	for _, w := range p.wildcards {
+		if len(lower) > len(w[0])+len(w[1]) && strings.HasPrefix(lower, w[0]) && strings.HasSuffix(lower, w[1]) {
+			return true
+		}
+	}
+	if p.conf.AllowOriginFunc != nil {
+		return p.conf.AllowOriginFunc(origin)
+	}
+	return false
 }
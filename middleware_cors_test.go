@@ -4,36 +4,171 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"go.uber.org/zap"
 )
 
-func TestCORS(t *testing.T) {
+var okHandler = ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(`ok`))
+})
+
+func serveCORS(mw Middleware, method, origin string, header map[string]string) *httptest.ResponseRecorder {
 	recorder := httptest.NewRecorder()
-	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
-	request := &Request{Request: req}
-	response := &responseWriter{ResponseWriter: recorder}
-
-	handler := ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
-		w.Header().Set("Allow", "GET, POST, OPTIONS")
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte(`ok`))
-	})
+	req := httptest.NewRequest(method, "http://example.com/", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	mw.Proxy(okHandler).ServeHTTP(context.Background(), &responseWriter{ResponseWriter: recorder}, &Request{Request: req})
+	return recorder
+}
 
-	CORS(600).Proxy(handler).ServeHTTP(context.Background(), response, request)
+func TestCORS(t *testing.T) {
+	recorder := serveCORS(CORS(600), http.MethodGet, "https://a.com", nil)
 
 	if got := recorder.Header().Get("Access-Control-Allow-Origin"); got != "*" {
 		t.Fatalf("expected Access-Control-Allow-Origin=* got=%q", got)
 	}
-	if got := recorder.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST, OPTIONS" {
-		t.Fatalf("expected Access-Control-Allow-Methods=GET, POST, OPTIONS got=%q", got)
+	// "*" 与 credentials 同时出现会被浏览器拒绝
+	if got := recorder.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Fatalf("expected no Access-Control-Allow-Credentials with * origin, got=%q", got)
+	}
+	if got := recorder.Body.String(); got != "ok" {
+		t.Fatalf("handler not called for simple request, body=%q", got)
+	}
+}
+
+func TestCORS_NoOriginPassThrough(t *testing.T) {
+	recorder := serveCORS(CORS(600), http.MethodGet, "", nil)
+
+	if got := recorder.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("expected no CORS headers for same-origin request, got=%q", got)
+	}
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	recorder := serveCORS(CORS(600), http.MethodOptions, "https://a.com", map[string]string{
+		"Access-Control-Request-Method":  "PUT",
+		"Access-Control-Request-Headers": "X-Token, Content-Type",
+	})
+
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("preflight status = %d, want 204", recorder.Code)
+	}
+	if recorder.Body.Len() != 0 {
+		t.Fatalf("handler should not be called for preflight, body=%q", recorder.Body.String())
 	}
-	if got := recorder.Header().Get("Access-Control-Allow-Headers"); got != "*" {
-		t.Fatalf("expected Access-Control-Allow-Headers=* got=%q", got)
+	if got := recorder.Header().Get("Access-Control-Allow-Methods"); got != strings.Join(DefaultCORSAllowMethods, ", ") {
+		t.Fatalf("expected default Access-Control-Allow-Methods got=%q", got)
 	}
-	if got := recorder.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
-		t.Fatalf("expected Access-Control-Allow-Credentials=true got=%q", got)
+	if got := recorder.Header().Get("Access-Control-Allow-Headers"); got != "X-Token, Content-Type" {
+		t.Fatalf("expected request headers echoed got=%q", got)
 	}
 	if got := recorder.Header().Get("Access-Control-Max-Age"); got != "600" {
 		t.Fatalf("expected Access-Control-Max-Age=600 got=%q", got)
 	}
 }
+
+func TestCORSWithConfig_Allowlist(t *testing.T) {
+	mw := CORSWithConfig(CORSConfig{
+		AllowOrigins:     []string{"https://app.example.com", "https://*.example.org"},
+		AllowOriginFunc:  func(origin string) bool { return strings.HasSuffix(origin, ".local:8080") },
+		AllowHeaders:     []string{"X-Token"},
+		ExposeHeaders:    []string{"X-Trace-Id"},
+		AllowCredentials: true,
+	})
+
+	cases := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.example.com", true},
+		{"https://APP.example.com", true},
+		{"https://foo.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"http://foo.example.org", false},
+		{"https://dev.local:8080", true},
+		{"https://evil.com", false},
+	}
+	for _, c := range cases {
+		recorder := serveCORS(mw, http.MethodGet, c.origin, nil)
+
+		got := recorder.Header().Get("Access-Control-Allow-Origin")
+		if c.allowed && got != c.origin {
+			t.Errorf("origin %q: Access-Control-Allow-Origin = %q, want echoed", c.origin, got)
+		}
+		if !c.allowed && got != "" {
+			t.Errorf("origin %q: should not be allowed, got %q", c.origin, got)
+		}
+		if vary := recorder.Header().Values("Vary"); len(vary) == 0 || vary[0] != "Origin" {
+			t.Errorf("origin %q: Vary = %v, want Origin", c.origin, vary)
+		}
+		if c.allowed {
+			if got := recorder.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+				t.Errorf("origin %q: Access-Control-Allow-Credentials = %q", c.origin, got)
+			}
+			if got := recorder.Header().Get("Access-Control-Expose-Headers"); got != "X-Trace-Id" {
+				t.Errorf("origin %q: Access-Control-Expose-Headers = %q", c.origin, got)
+			}
+		}
+	}
+
+	recorder := serveCORS(mw, http.MethodOptions, "https://evil.com", map[string]string{
+		"Access-Control-Request-Method": "POST",
+	})
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("preflight of disallowed origin status = %d, want 403", recorder.Code)
+	}
+	if got := decodeResult(t, recorder.Body.Bytes()); got.ErrNO != errnoForbidden {
+		t.Errorf("preflight of disallowed origin envelope wrong: %+v", got)
+	}
+}
+
+func TestCORSWithConfig_WildcardWithCredentials(t *testing.T) {
+	conf := CORSConfig{
+		AllowOrigins:     []string{"https://app.example.com", " *"},
+		AllowCredentials: true,
+	}
+	if err := conf.Validate(); err == nil {
+		t.Error("Validate() = nil for AllowCredentials with * origin")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic for AllowCredentials with * origin")
+		}
+	}()
+	CORSWithConfig(conf)
+}
+
+// 预检经 RESTRouter.GlobalOPTIONS 应答：Allow-Methods 取路由器为该路径生成的 Allow 头。
+func TestCORS_PreflightThroughRESTRouter(t *testing.T) {
+	router := NewRESTRouter(context.Background(), zap.NewNop())
+	chain := NewChain(CORSWithConfig(CORSConfig{AllowOrigins: []string{"https://a.com"}}))
+	router.GET("/users/:id", chain.Then(okHandler))
+	router.PUT("/users/:id", chain.Then(okHandler))
+	router.GlobalOPTIONS(chain.Then(okHandler))
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodOptions, "/users/1", nil)
+	req.Header.Set("Origin", "https://a.com")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("preflight status = %d, want 204", recorder.Code)
+	}
+	if got := recorder.Header().Get("Access-Control-Allow-Origin"); got != "https://a.com" {
+		t.Fatalf("Access-Control-Allow-Origin = %q", got)
+	}
+	methods := recorder.Header().Get("Access-Control-Allow-Methods")
+	if !strings.Contains(methods, "GET") || !strings.Contains(methods, "PUT") || strings.Contains(methods, "POST") {
+		t.Fatalf("Access-Control-Allow-Methods = %q, want methods of the path", methods)
+	}
+}
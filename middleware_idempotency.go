@@ -0,0 +1,240 @@
+package kate
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stn81/kate/log"
+	"github.com/stn81/kate/rdb"
+	"github.com/stn81/kate/redsync"
+	"go.uber.org/zap"
+)
+
+const (
+	// HeaderIdempotencyKey the header name of `Idempotency-Key`
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed is set on the replayed responses
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLen = 255
+)
+
+// IdempotentResponse is the stored response of an idempotency key
+type IdempotentResponse struct {
+	RequestHash string      `json:"request_hash"`
+	StatusCode  int         `json:"status_code"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+}
+
+// IdempotencyStore defines the storage of the idempotency middleware
+type IdempotencyStore interface {
+	// Lock takes a short lock on key, ErrIdempotencyInFlight is returned if it is held by others
+	Lock(ctx context.Context, key string) (unlock func(), err error)
+	// Get return the stored response, nil is returned if not found
+	Get(ctx context.Context, key string) (*IdempotentResponse, error)
+	// Set stores the response
+	Set(ctx context.Context, key string, response *IdempotentResponse) error
+}
+
+// Idempotent implements the Idempotency-Key middleware. The first response of a key is
+// stored and replayed for the retries with the same request, a retry arriving while the
+// first one is in flight gets 409, and a key reused with a different request gets 422.
+// 5xx responses are not stored so that the client can retry.
+// Keys are scoped to the caller: the principal set by Auth, or the Authorization header if there
+// is no principal, so Idempotent should be used after Auth.
+func Idempotent(store IdempotencyStore) Middleware {
+	mf := func(h ContextHandler) ContextHandler {
+		f := func(ctx context.Context, w ResponseWriter, r *Request) {
+			key := r.Header.Get(HeaderIdempotencyKey)
+			if key == "" {
+				h.ServeHTTP(ctx, w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLen {
+				writeError(ctx, w, ErrBadParam("Idempotency-Key too long"))
+				return
+			}
+
+			var (
+				logger      = log.GetLogger(ctx).With(zap.String("idempotency_key", key))
+				requestHash = idempotencyRequestHash(r)
+				// 客户端断开也要保存结果并释放锁，否则重试会一直 409 直到锁过期
+				storeCtx = context.WithoutCancel(ctx)
+			)
+			key = idempotencyScope(ctx, r) + key
+
+			if replayed := replayIdempotent(ctx, store, w, key, requestHash); replayed {
+				return
+			}
+
+			unlock, err := store.Lock(storeCtx, key)
+			if err != nil {
+				if !errors.Is(err, ErrIdempotencyInFlight) {
+					logger.Error("lock idempotency key", zap.Error(err))
+				}
+				writeError(ctx, w, err)
+				return
+			}
+			defer unlock()
+
+			// 拿锁前可能刚有同键请求完成
+			if replayed := replayIdempotent(ctx, store, w, key, requestHash); replayed {
+				return
+			}
+
+			// 只保存 handler 设置的头，外层中间件的头(trace id, CORS 等)属于每个请求自己
+			before := w.Header().Clone()
+			recorder := &bodyRecorder{ResponseWriter: w}
+			h.ServeHTTP(ctx, recorder, r)
+
+			// 未写响应时 net/http 发送 200
+			status := cmp.Or(w.StatusCode(), http.StatusOK)
+			if status >= http.StatusInternalServerError {
+				return
+			}
+			response := &IdempotentResponse{
+				RequestHash: requestHash,
+				StatusCode:  status,
+				Header:      headerChanges(before, w.Header()),
+				Body:        recorder.body.Bytes(),
+			}
+			if err = store.Set(storeCtx, key, response); err != nil {
+				logger.Error("store idempotent response", zap.Error(err))
+			}
+		}
+		return ContextHandlerFunc(f)
+	}
+	return MiddlewareFunc(mf)
+}
+
+// replayIdempotent writes the stored response if any, it returns whether the response is written
+func replayIdempotent(ctx context.Context, store IdempotencyStore, w ResponseWriter, key, requestHash string) bool {
+	response, err := store.Get(ctx, key)
+	switch {
+	case err != nil:
+		log.GetLogger(ctx).Error("get idempotent response", zap.String("idempotency_key", key), zap.Error(err))
+		writeError(ctx, w, err)
+		return true
+	case response == nil:
+		return false
+	case response.RequestHash != requestHash:
+		writeError(ctx, w, ErrIdempotencyMismatch)
+		return true
+	}
+
+	header := w.Header()
+	for key, values := range response.Header {
+		header[key] = values
+	}
+	header.Set(HeaderIdempotentReplayed, "true")
+	w.WriteHeader(cmp.Or(response.StatusCode, http.StatusOK))
+	if len(response.Body) > 0 {
+		_, _ = w.Write(response.Body)
+	}
+	return true
+}
+
+// idempotencyScope return the prefix of the key scoping it to the caller,
+// so that a caller reusing the key of another never gets its response
+func idempotencyScope(ctx context.Context, r *Request) string {
+	var caller string
+	if principal, ok := PrincipalFrom(ctx); ok && principal != nil {
+		caller = principal.Scheme + "\n" + principal.Subject
+	} else if auth := r.Header.Get("Authorization"); auth != "" {
+		caller = auth
+	} else {
+		return "anonymous:"
+	}
+	sum := sha256.Sum256([]byte(caller))
+	return hex.EncodeToString(sum[:16]) + ":"
+}
+
+// headerChanges return the headers of after which are added or changed since before
+func headerChanges(before, after http.Header) http.Header {
+	changes := make(http.Header)
+	for key, values := range after {
+		if !slices.Equal(before[key], values) {
+			changes[key] = slices.Clone(values)
+		}
+	}
+	return changes
+}
+
+// idempotencyRequestHash fingerprints the request, a key must not be reused for another endpoint or body
+func idempotencyRequestHash(r *Request) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method))
+	hash.Write([]byte{'\n'})
+	hash.Write([]byte(r.URL.Path))
+	hash.Write([]byte{'\n'})
+	hash.Write(r.RawBody)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+type redisIdempotencyStore struct {
+	client     rdb.Client
+	prefix     string
+	ttl        time.Duration
+	lockExpiry time.Duration
+	redsync    *redsync.Redsync
+}
+
+// NewRedisIdempotencyStore create an IdempotencyStore which stores responses in redis for ttl,
+// and locks keys with redsync mutex for at most lockExpiry.
+func NewRedisIdempotencyStore(client rdb.Client, prefix string, ttl, lockExpiry time.Duration) IdempotencyStore {
+	return &redisIdempotencyStore{
+		client:     client,
+		prefix:     prefix,
+		ttl:        ttl,
+		lockExpiry: lockExpiry,
+		redsync:    redsync.New([]redsync.Pool{redsync.NewPool(client)}),
+	}
+}
+
+func (s *redisIdempotencyStore) Lock(ctx context.Context, key string) (func(), error) {
+	mutex := s.redsync.NewMutex(ctx, s.prefix+key+":lock", redsync.SetExpiry(s.lockExpiry))
+	if err := mutex.TryLock(ctx); err != nil {
+		if errors.Is(err, redsync.ErrFailed) {
+			return nil, ErrIdempotencyInFlight
+		}
+		return nil, err
+	}
+	return func() {
+		if err := mutex.Unlock(); err != nil {
+			log.GetLogger(ctx).Warn("unlock idempotency key failed", zap.String("key", key), zap.Error(err))
+		}
+	}, nil
+}
+
+func (s *redisIdempotencyStore) Get(ctx context.Context, key string) (*IdempotentResponse, error) {
+	b, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	switch {
+	case errors.Is(err, redis.Nil):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	response := &IdempotentResponse{}
+	if err = json.Unmarshal(b, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *redisIdempotencyStore) Set(ctx context.Context, key string, response *IdempotentResponse) error {
+	b, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.prefix+key, b, s.ttl).Err()
+}
@@ -0,0 +1,217 @@
+package kate
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newIdempotencyStore(t *testing.T) IdempotencyStore {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return NewRedisIdempotencyStore(client, "idem:", time.Hour, 10*time.Second)
+}
+
+func serveIdempotent(h ContextHandler, key, body string) *httptest.ResponseRecorder {
+	return serveIdempotentContext(context.Background(), h, key, body)
+}
+
+func serveIdempotentContext(ctx context.Context, h ContextHandler, key, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/pay", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(HeaderIdempotencyKey, key)
+	}
+	h.ServeHTTP(ctx, &responseWriter{ResponseWriter: recorder}, &Request{Request: req, RawBody: []byte(body)})
+	return recorder
+}
+
+func TestIdempotent_Replay(t *testing.T) {
+	var calls int32
+	h := Idempotent(newIdempotencyStore(t)).Proxy(ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		n := atomic.AddInt32(&calls, 1)
+		w.Header().Set("X-Order", "1")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"errno":0,"errmsg":"ok","data":` + string(rune('0'+n)) + `}`))
+	}))
+
+	first := serveIdempotent(h, "k1", `{"amount":100}`)
+	second := serveIdempotent(h, "k1", `{"amount":100}`)
+
+	if calls != 1 {
+		t.Fatalf("handler called %d times, want 1", calls)
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Errorf("replayed response = %d %q, want %d %q", second.Code, second.Body.String(), first.Code, first.Body.String())
+	}
+	if second.Header().Get("X-Order") != "1" || second.Header().Get(HeaderIdempotentReplayed) != "true" {
+		t.Errorf("replayed headers wrong: %v", second.Header())
+	}
+	if first.Header().Get(HeaderIdempotentReplayed) != "" {
+		t.Errorf("first response should not be marked as replayed")
+	}
+
+	// 无幂等键不受影响
+	serveIdempotent(h, "", `{"amount":100}`)
+	if calls != 2 {
+		t.Errorf("request without key should reach handler, calls=%d", calls)
+	}
+}
+
+func TestIdempotent_OuterHeadersNotStored(t *testing.T) {
+	var seq int32
+	inner := Idempotent(newIdempotencyStore(t)).Proxy(ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		w.Header().Set("X-Order", "1")
+		_, _ = w.Write([]byte(`{"errno":0,"errmsg":"ok"}`))
+	}))
+	h := ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		w.Header().Set(HeaderTraceId, string(rune('0'+atomic.AddInt32(&seq, 1))))
+		inner.ServeHTTP(ctx, w, r)
+	})
+
+	serveIdempotent(h, "k1", `{"amount":100}`)
+	second := serveIdempotent(h, "k1", `{"amount":100}`)
+
+	if second.Header().Get(HeaderIdempotentReplayed) != "true" || second.Header().Get("X-Order") != "1" {
+		t.Fatalf("replayed headers wrong: %v", second.Header())
+	}
+	if id := second.Header().Get(HeaderTraceId); id != "2" {
+		t.Errorf("replayed trace id = %q, want the one of the current request", id)
+	}
+}
+
+func TestIdempotent_ScopedToCaller(t *testing.T) {
+	var calls int32
+	h := Idempotent(newIdempotencyStore(t)).Proxy(ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		atomic.AddInt32(&calls, 1)
+		principal, _ := PrincipalFrom(ctx)
+		_, _ = w.Write([]byte(principal.Subject))
+	}))
+
+	alice := WithPrincipal(context.Background(), &Principal{Subject: "alice", Scheme: "jwt"})
+	bob := WithPrincipal(context.Background(), &Principal{Subject: "bob", Scheme: "jwt"})
+	serveIdempotentContext(alice, h, "k1", `{}`)
+	rec := serveIdempotentContext(bob, h, "k1", `{}`)
+
+	if calls != 2 || rec.Body.String() != "bob" {
+		t.Errorf("key reused by another caller got %q, calls=%d", rec.Body.String(), calls)
+	}
+	if rec = serveIdempotentContext(alice, h, "k1", `{}`); calls != 2 || rec.Body.String() != "alice" {
+		t.Errorf("retry of the same caller got %q, calls=%d", rec.Body.String(), calls)
+	}
+}
+
+func TestIdempotent_ClientGone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int32
+	h := Idempotent(newIdempotencyStore(t)).Proxy(ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		atomic.AddInt32(&calls, 1)
+		cancel() // 客户端在处理中断开
+		_, _ = w.Write([]byte(`{"errno":0,"errmsg":"ok"}`))
+	}))
+	serveIdempotentContext(ctx, h, "k1", `{}`)
+
+	rec := serveIdempotent(h, "k1", `{}`)
+	if rec.Code != http.StatusOK || rec.Header().Get(HeaderIdempotentReplayed) != "true" || calls != 1 {
+		t.Errorf("retry after client gone: status=%d replayed=%q calls=%d",
+			rec.Code, rec.Header().Get(HeaderIdempotentReplayed), calls)
+	}
+}
+
+func TestIdempotent_ReplayEmptyAndMultiWrite(t *testing.T) {
+	var calls int32
+	h := Idempotent(newIdempotencyStore(t)).Proxy(ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.URL.Query().Get("empty") != "" {
+			return
+		}
+		_, _ = w.Write([]byte(`{"errno":0,`))
+		_, _ = w.Write([]byte(`"errmsg":"ok"}`))
+	}))
+	serve := func(target string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, target, nil)
+		req.Header.Set(HeaderIdempotencyKey, target)
+		h.ServeHTTP(context.Background(), &responseWriter{ResponseWriter: recorder}, &Request{Request: req})
+		return recorder
+	}
+
+	serve("http://example.com/pay?empty=1")
+	if rec := serve("http://example.com/pay?empty=1"); rec.Code != http.StatusOK || rec.Header().Get(HeaderIdempotentReplayed) != "true" {
+		t.Errorf("replay of empty response: status=%d replayed=%q", rec.Code, rec.Header().Get(HeaderIdempotentReplayed))
+	}
+
+	serve("http://example.com/pay")
+	if rec := serve("http://example.com/pay"); rec.Body.String() != `{"errno":0,"errmsg":"ok"}` {
+		t.Errorf("replayed body = %q, want the full body", rec.Body.String())
+	}
+	if calls != 2 {
+		t.Errorf("handler called %d times, want 2", calls)
+	}
+}
+
+func TestIdempotent_Mismatch(t *testing.T) {
+	h := Idempotent(newIdempotencyStore(t)).Proxy(okHandler)
+
+	serveIdempotent(h, "k1", `{"amount":100}`)
+	rec := serveIdempotent(h, "k1", `{"amount":200}`)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want 422", rec.Code)
+	}
+	if got := decodeResult(t, rec.Body.Bytes()); got.ErrNO != errnoMismatch {
+		t.Errorf("envelope wrong: %+v", got)
+	}
+}
+
+func TestIdempotent_InFlight(t *testing.T) {
+	store := newIdempotencyStore(t)
+	h := Idempotent(store).Proxy(okHandler)
+
+	unlock, err := store.Lock(context.Background(), "anonymous:k1")
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+
+	rec := serveIdempotent(h, "k1", `{}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want 409", rec.Code)
+	}
+	if got := decodeResult(t, rec.Body.Bytes()); got.ErrNO != errnoInFlight {
+		t.Errorf("envelope wrong: %+v", got)
+	}
+
+	unlock()
+	if rec = serveIdempotent(h, "k1", `{}`); rec.Code != http.StatusOK {
+		t.Errorf("status after unlock = %d, want 200", rec.Code)
+	}
+}
+
+func TestIdempotent_ServerErrorNotStored(t *testing.T) {
+	var calls int32
+	h := Idempotent(newIdempotencyStore(t)).Proxy(ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			writeError(ctx, w, ErrServerInternal)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	if rec := serveIdempotent(h, "k1", `{}`); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	if rec := serveIdempotent(h, "k1", `{}`); rec.Code != http.StatusOK || calls != 2 {
+		t.Errorf("retry after 5xx should reach handler: status=%d calls=%d", rec.Code, calls)
+	}
+}
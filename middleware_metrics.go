@@ -0,0 +1,113 @@
+package kate
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/stn81/kate/metrics"
+)
+
+const (
+	// routeUnknown is the route label of requests not matched by a registered pattern
+	routeUnknown = "unknown"
+	// methodOther is the method label of non-standard methods
+	methodOther = "OTHER"
+	// errnoHeadLen is the length of the body head kept for errnoOf, errno is the first field of Result
+	errnoHeadLen = 512
+)
+
+// Metrics implements the metrics middleware, requests are labelled by the route pattern
+// (Request.Route) instead of the raw url to keep the cardinality bounded:
+//   - http_requests_total{method,route,status,errno}
+//   - http_request_duration_seconds{method,route,status}
+//   - http_requests_in_flight{method,route}
+//   - http_response_size_bytes{method,route}
+func Metrics(reg *metrics.Registry) Middleware {
+	var (
+		requests = reg.Counter("http_requests_total",
+			"Total number of http requests.", "method", "route", "status", "errno")
+		durations = reg.Histogram("http_request_duration_seconds",
+			"Latency of http requests in seconds.", metrics.DefBuckets, "method", "route", "status")
+		inFlight = reg.Gauge("http_requests_in_flight",
+			"Number of http requests being served.", "method", "route")
+		sizes = reg.Histogram("http_response_size_bytes",
+			"Size of http response body in bytes.", metrics.ExponentialBuckets(64, 4, 8), "method", "route")
+	)
+
+	mf := func(h ContextHandler) ContextHandler {
+		f := func(ctx context.Context, w ResponseWriter, r *Request) {
+			route := r.Route
+			if route == "" {
+				route = routeUnknown
+			}
+			method := methodLabel(r.Method)
+
+			gauge := inFlight.WithLabelValues(method, route)
+			gauge.Inc()
+			start := time.Now()
+
+			// RawBody 只有最后一次 Write，大小和 errno 由 recorder 统计
+			recorder := &bodyRecorder{ResponseWriter: w, limit: errnoHeadLen}
+			defer func() {
+				gauge.Dec()
+
+				status := strconv.Itoa(cmp.Or(w.StatusCode(), http.StatusOK)) // net/http 对未写的响应发送 200
+				requests.WithLabelValues(method, route, status, errnoOf(recorder.body.Bytes())).Inc()
+				durations.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+				sizes.WithLabelValues(method, route).Observe(float64(recorder.size))
+			}()
+
+			h.ServeHTTP(ctx, recorder, r)
+		}
+		return ContextHandlerFunc(f)
+	}
+	return MiddlewareFunc(mf)
+}
+
+// errnoOf return the errno in the Result envelope, empty if the body is not an envelope.
+// The body is decoded until the errno field, which is the first field of Result.
+func errnoOf(body []byte) string {
+	if len(body) == 0 || body[0] != '{' {
+		return ""
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if _, err := dec.Token(); err != nil {
+		return ""
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		if key != "errno" {
+			var skip json.RawMessage
+			if err = dec.Decode(&skip); err != nil {
+				return ""
+			}
+			continue
+		}
+		var errno int
+		if err = dec.Decode(&errno); err != nil {
+			return ""
+		}
+		return strconv.Itoa(errno)
+	}
+	return ""
+}
+
+// methodLabel return the method label, non-standard methods are folded into methodOther
+// so that clients can't create unbounded label values
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return methodOther
+}
@@ -0,0 +1,130 @@
+package kate
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stn81/kate/metrics"
+	"go.uber.org/zap"
+)
+
+func TestMetrics_LabelledByRoute(t *testing.T) {
+	reg := metrics.NewRegistry()
+	router := NewRESTRouter(context.Background(), zap.NewNop())
+	chain := NewChain(Metrics(reg))
+
+	router.GET("/users/:id", chain.ThenFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		if r.RestVars.ByName("id") == "404" {
+			(&RESTHandler{}).Error(ctx, w, NewHTTPError(http.StatusNotFound, 10004, "用户不存在"))
+			return
+		}
+		(&RESTHandler{}).OkData(ctx, w, "ok")
+	}))
+
+	for _, id := range []string{"1", "2", "404"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/"+id, nil))
+	}
+
+	var sb strings.Builder
+	if _, err := reg.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	out := sb.String()
+
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/users/:id",status="200",errno="0"} 2`,
+		`http_requests_total{method="GET",route="/users/:id",status="404",errno="10004"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/users/:id",status="200"} 2`,
+		`http_requests_in_flight{method="GET",route="/users/:id"} 0`,
+		`http_response_size_bytes_count{method="GET",route="/users/:id"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "/users/1") {
+		t.Errorf("raw url must not be used as label:\n%s", out)
+	}
+}
+
+func TestMetrics_StdRouterPattern(t *testing.T) {
+	reg := metrics.NewRegistry()
+	router := NewRouter(context.Background(), zap.NewNop())
+	router.Handle("GET /files/{name}", NewChain(Metrics(reg)).Then(okHandler))
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/files/a.txt", nil))
+
+	var sb strings.Builder
+	_, _ = reg.WriteTo(&sb)
+	if want := `route="GET /files/{name}",status="200",errno=""} 1`; !strings.Contains(sb.String(), want) {
+		t.Errorf("missing %q in:\n%s", want, sb.String())
+	}
+}
+
+func TestMetrics_DefaultStatusAndOtherMethod(t *testing.T) {
+	reg := metrics.NewRegistry()
+	h := Metrics(reg).Proxy(ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {}))
+
+	for _, method := range []string{http.MethodGet, "FOO", "BAR"} {
+		req := &Request{Request: httptest.NewRequest(method, "/", nil)}
+		h.ServeHTTP(context.Background(), &responseWriter{ResponseWriter: httptest.NewRecorder()}, req)
+	}
+
+	var sb strings.Builder
+	_, _ = reg.WriteTo(&sb)
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="unknown",status="200",errno=""} 1`,
+		`http_requests_total{method="OTHER",route="unknown",status="200",errno=""} 2`,
+	} {
+		if !strings.Contains(sb.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, sb.String())
+		}
+	}
+}
+
+func TestMetrics_MultiWrite(t *testing.T) {
+	reg := metrics.NewRegistry()
+	h := Metrics(reg).Proxy(ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		_, _ = w.Write([]byte(`{"errno":-3,"errmsg":"busy","data":"`))
+		_, _ = w.Write([]byte(strings.Repeat("x", 1000)))
+		_, _ = w.Write([]byte(`"}`))
+	}))
+	req := &Request{Request: httptest.NewRequest(http.MethodGet, "/", nil)}
+	h.ServeHTTP(context.Background(), &responseWriter{ResponseWriter: httptest.NewRecorder()}, req)
+
+	var sb strings.Builder
+	_, _ = reg.WriteTo(&sb)
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="unknown",status="200",errno="-3"} 1`,
+		`http_response_size_bytes_sum{method="GET",route="unknown"} 1038`,
+	} {
+		if !strings.Contains(sb.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, sb.String())
+		}
+	}
+}
+
+func TestErrnoOf(t *testing.T) {
+	cases := []struct {
+		body  string
+		errno string
+	}{
+		{`{"errno":0,"errmsg":"ok","data":{"errno":1}}`, "0"},
+		{`{"data":[1,{"errno":2}],"errmsg":"x","errno":10004}`, "10004"},
+		{`{"errno":"0"}`, ""},
+		{`{"errno":1.5}`, ""},
+		{`{"data":1}`, ""},
+		{`{"errno":`, ""},
+		{`[1]`, ""},
+		{`ok`, ""},
+		{``, ""},
+	}
+	for _, c := range cases {
+		if errno := errnoOf([]byte(c.body)); errno != c.errno {
+			t.Errorf("errnoOf(%s) = %q, want %q", c.body, errno, c.errno)
+		}
+	}
+}
@@ -1,10 +1,44 @@
 package kate
 
 import (
+	"bytes"
 	"context"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"sync"
 	"time"
+
+	"github.com/stn81/kate/log"
+	"go.uber.org/zap"
 )
 
+// HeaderRequestTimeout carries the remaining time of the caller in milliseconds, it is sent by client.Client
+const HeaderRequestTimeout = "X-Request-Timeout"
+
+// RequestTimeout shortens the deadline of the request context to the X-Request-Timeout header,
+// so that the work the caller has given up on is canceled. It never extends the deadline,
+// invalid or non-positive values are ignored.
+var RequestTimeout = MiddlewareFunc(requestTimeoutFunc)
+
+func requestTimeoutFunc(h ContextHandler) ContextHandler {
+	f := func(ctx context.Context, w ResponseWriter, r *Request) {
+		ms, err := strconv.ParseInt(r.Header.Get(HeaderRequestTimeout), 10, 64)
+		if err != nil || ms <= 0 {
+			h.ServeHTTP(ctx, w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(ms)*time.Millisecond)
+		defer cancel()
+
+		h.ServeHTTP(ctx, w, r)
+	}
+	return ContextHandlerFunc(f)
+}
+
+// Timeout only shortens the deadline of the request context, the handler is waited
+// however long it takes. Use TimeoutWithResponse to reply the client on deadline.
 func Timeout(timeout time.Duration) Middleware {
 	mf := func(h ContextHandler) ContextHandler {
 		f := func(ctx context.Context, w ResponseWriter, r *Request) {
@@ -17,3 +51,146 @@ func Timeout(timeout time.Duration) Middleware {
 	}
 	return MiddlewareFunc(mf)
 }
+
+// TimeoutWithResponse implements the timeout middleware in the way of `http.TimeoutHandler`:
+// the handler writes to a buffer, which is sent to the client only if the handler finishes
+// in time, otherwise an ErrTimeout envelope is sent with httpStatus (0 means 503) and the
+// late writes are discarded with `http.ErrHandlerTimeout`.
+func TimeoutWithResponse(timeout time.Duration, httpStatus int) Middleware {
+	errTimeout := ErrTimeout
+	if httpStatus > 0 {
+		errTimeout = WithHTTPStatus(NewError(errnoTimeout, ErrTimeout.Error()), httpStatus)
+	}
+
+	mf := func(h ContextHandler) ContextHandler {
+		f := func(ctx context.Context, w ResponseWriter, r *Request) {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			var (
+				start     = time.Now()
+				tw        = &timeoutWriter{header: make(http.Header)}
+				done      = make(chan struct{})
+				panicChan = make(chan handlerPanic, 1)
+			)
+
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicChan <- handlerPanic{value: p, stack: debug.Stack()}
+						return
+					}
+					close(done)
+				}()
+				h.ServeHTTP(ctx, tw, r)
+			}()
+
+			select {
+			case p := <-panicChan:
+				// 外层 Recovery 只能拿到本 goroutine 的栈，handler 的栈在这里记下
+				log.GetLogger(ctx).Error("handler panic", zap.Any("error", p.value), zap.ByteString("stack", p.stack))
+				panic(p.value)
+			case <-done:
+				tw.flushTo(w)
+			case <-ctx.Done():
+				tw.timeout()
+				writeError(ctx, w, errTimeout)
+
+				logger := log.GetLogger(ctx)
+				logger.Warn("handler timeout", zap.Duration("timeout", timeout), zap.Error(ctx.Err()))
+
+				go func() {
+					select {
+					case p := <-panicChan:
+						logger.Error("handler panic after timeout", zap.Any("error", p.value), zap.ByteString("stack", p.stack))
+					case <-done:
+						logger.Warn("handler finished after timeout",
+							zap.Duration("timeout", timeout),
+							zap.Duration("elapsed", time.Since(start)))
+					}
+				}()
+			}
+		}
+		return ContextHandlerFunc(f)
+	}
+	return MiddlewareFunc(mf)
+}
+
+// handlerPanic carries the panic of the handler goroutine with its stack
+type handlerPanic struct {
+	value any
+	stack []byte
+}
+
+// timeoutWriter buffers the response until the handler finishes
+type timeoutWriter struct {
+	mu          sync.Mutex
+	header      http.Header
+	body        bytes.Buffer
+	statusCode  int
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	tw.statusCode = code
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.wroteHeader = true
+		tw.statusCode = http.StatusOK
+	}
+	return tw.body.Write(b)
+}
+
+func (tw *timeoutWriter) StatusCode() int {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.statusCode
+}
+
+func (tw *timeoutWriter) RawBody() []byte {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.body.Bytes()
+}
+
+func (tw *timeoutWriter) timeout() {
+	tw.mu.Lock()
+	tw.timedOut = true
+	tw.mu.Unlock()
+}
+
+// flushTo sends the buffered response, it is only called after the handler returns
+func (tw *timeoutWriter) flushTo(w ResponseWriter) {
+	dst := w.Header()
+	for key, values := range tw.header {
+		dst[key] = values
+	}
+
+	if !tw.wroteHeader {
+		tw.statusCode = http.StatusOK
+	}
+	w.WriteHeader(tw.statusCode)
+	if tw.body.Len() > 0 {
+		_, _ = w.Write(tw.body.Bytes())
+	}
+}
@@ -0,0 +1,150 @@
+package kate
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func serveWith(mw Middleware, h ContextHandler) (*httptest.ResponseRecorder, *responseWriter) {
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	response := &responseWriter{ResponseWriter: recorder}
+	mw.Proxy(h).ServeHTTP(context.Background(), response, &Request{Request: req})
+	return recorder, response
+}
+
+func TestTimeoutWithResponse_InTime(t *testing.T) {
+	handler := ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		w.Header().Set("X-Foo", "bar")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"errno":0,`))
+		_, _ = w.Write([]byte(`"errmsg":"ok"}`))
+	})
+
+	rec, response := serveWith(TimeoutWithResponse(time.Second, 0), handler)
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("status = %d, want 201", rec.Code)
+	}
+	if got := rec.Header().Get("X-Foo"); got != "bar" {
+		t.Errorf("X-Foo = %q, want bar", got)
+	}
+	if got := rec.Body.String(); got != `{"errno":0,"errmsg":"ok"}` {
+		t.Errorf("body = %q", got)
+	}
+	// 缓冲后一次写出，RawBody 拿到的是完整 body
+	if got := string(response.RawBody()); got != rec.Body.String() {
+		t.Errorf("RawBody = %q, want full body", got)
+	}
+}
+
+func TestTimeoutWithResponse_Timeout(t *testing.T) {
+	lateWrite := make(chan error, 1)
+	handler := ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		_, err := w.Write([]byte("late"))
+		lateWrite <- err
+	})
+
+	rec, response := serveWith(TimeoutWithResponse(20*time.Millisecond, 0), handler)
+
+	if rec.Code != http.StatusServiceUnavailable || response.StatusCode() != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+	if got := decodeResult(t, rec.Body.Bytes()); got.ErrNO != errnoTimeout {
+		t.Errorf("envelope wrong: %+v", got)
+	}
+	if err := <-lateWrite; !errors.Is(err, http.ErrHandlerTimeout) {
+		t.Errorf("late write error = %v, want http.ErrHandlerTimeout", err)
+	}
+}
+
+func TestTimeoutWithResponse_CustomStatus(t *testing.T) {
+	handler := ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		<-ctx.Done()
+	})
+
+	rec, _ := serveWith(TimeoutWithResponse(10*time.Millisecond, http.StatusGatewayTimeout), handler)
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want 504", rec.Code)
+	}
+	if got := decodeResult(t, rec.Body.Bytes()); got.ErrNO != errnoTimeout || got.ErrMsg != ErrTimeout.Error() {
+		t.Errorf("envelope wrong: %+v", got)
+	}
+}
+
+func TestTimeoutWithResponse_PanicPropagates(t *testing.T) {
+	handler := ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		panic("boom")
+	})
+
+	// panic 回传到请求 goroutine，由外层 Recovery 兜住
+	rec, _ := serveWith(Recovery, TimeoutWithResponse(time.Second, 0).Proxy(handler))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+
+	// 原始 panic 值原样回传，外层可按类型判断(如 http.ErrAbortHandler)
+	handler = ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		panic(http.ErrAbortHandler)
+	})
+	var recovered any
+	func() {
+		defer func() { recovered = recover() }()
+		serveWith(TimeoutWithResponse(time.Second, 0), handler)
+	}()
+	if err, ok := recovered.(error); !ok || err != http.ErrAbortHandler {
+		t.Errorf("recovered = %#v, want http.ErrAbortHandler", recovered)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	cases := []struct {
+		header   string
+		deadline bool
+	}{
+		{"200", true},
+		{"", false},
+		{"0", false},
+		{"-1", false},
+		{"abc", false},
+	}
+	for _, c := range cases {
+		var remaining time.Duration
+		handler := ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+			if deadline, ok := ctx.Deadline(); ok {
+				remaining = time.Until(deadline)
+			}
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		req.Header.Set(HeaderRequestTimeout, c.header)
+		RequestTimeout.Proxy(handler).ServeHTTP(context.Background(),
+			&responseWriter{ResponseWriter: httptest.NewRecorder()}, &Request{Request: req})
+
+		if c.deadline && (remaining <= 0 || remaining > 200*time.Millisecond) {
+			t.Errorf("header %q: remaining = %v, want within 200ms", c.header, remaining)
+		}
+		if !c.deadline && remaining != 0 {
+			t.Errorf("header %q: unexpected deadline, remaining = %v", c.header, remaining)
+		}
+	}
+
+	// 不会延长已有的 deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.Header.Set(HeaderRequestTimeout, "60000")
+	RequestTimeout.Proxy(ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		if deadline, _ := ctx.Deadline(); time.Until(deadline) > 50*time.Millisecond {
+			t.Errorf("deadline is extended to %v", time.Until(deadline))
+		}
+	})).ServeHTTP(ctx, &responseWriter{ResponseWriter: httptest.NewRecorder()}, &Request{Request: req})
+}
@@ -2,13 +2,19 @@ package kate
 
 import (
 	"context"
+
 	"github.com/stn81/kate/log"
 	"github.com/stn81/kate/traceid"
 	"go.uber.org/zap"
 )
 
-const HeaderTraceId = "X-Trace-Id"
+// HeaderTraceId is the legacy trace id header
+const HeaderTraceId = traceid.HeaderTraceId
 
+// TraceId implements the trace id middleware.
+// The trace is joined from the W3C traceparent/tracestate headers first, then from X-Trace-Id
+// mapped by traceid.FromLegacy, otherwise a new trace is started. The trace id, which is also
+// the one logged and propagated, is echoed in the X-Trace-Id response header.
 var TraceId = MiddlewareFunc(traceIdFunc)
 
 func traceIdFunc(h ContextHandler) ContextHandler {
@@ -18,13 +24,23 @@ func traceIdFunc(h ContextHandler) ContextHandler {
 			logger  = log.GetLogger(ctx)
 		)
 
-		if traceId == "" {
+		sc, err := traceid.ParseTraceparent(r.Header.Get(traceid.HeaderTraceparent))
+		switch {
+		case err == nil:
+			sc.State = r.Header.Get(traceid.HeaderTracestate)
+			traceId = sc.TraceId
+		case traceId != "":
+			traceId = traceid.FromLegacy(traceId)
+			sc = traceid.SpanContext{TraceId: traceId, Flags: traceid.FlagSampled, Remote: true}
+		default:
 			traceId = traceid.New()
+			sc = traceid.SpanContext{TraceId: traceId, Flags: traceid.FlagSampled, Remote: true}
 		}
 		w.Header().Set(HeaderTraceId, traceId)
 
 		logger = logger.With(zap.String("trace_id", traceId))
 		ctx = traceid.ToContext(ctx, traceId)
+		ctx = traceid.WithSpanContext(ctx, sc)
 		ctx = log.ToContext(ctx, logger)
 		h.ServeHTTP(ctx, w, r)
 	}
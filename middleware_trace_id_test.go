@@ -0,0 +1,78 @@
+package kate
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stn81/kate/traceid"
+)
+
+func TestTraceId(t *testing.T) {
+	var (
+		gotTraceId string
+		gotSpanCtx traceid.SpanContext
+	)
+	h := TraceId.Proxy(ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		gotTraceId = traceid.Extract(ctx)
+		gotSpanCtx, _ = traceid.SpanContextFrom(ctx)
+	}))
+
+	cases := []struct {
+		name    string
+		header  map[string]string
+		traceId string
+		spanId  string
+		state   string
+	}{
+		{
+			name: "traceparent",
+			header: map[string]string{
+				traceid.HeaderTraceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				traceid.HeaderTracestate:  "congo=t61rcWkgMzE",
+				HeaderTraceId:             "ignored",
+			},
+			traceId: "4bf92f3577b34da6a3ce929d0e0e4736",
+			spanId:  "00f067aa0ba902b7",
+			state:   "congo=t61rcWkgMzE",
+		},
+		{
+			name:    "legacy",
+			header:  map[string]string{HeaderTraceId: "legacy-id"},
+			traceId: traceid.FromLegacy("legacy-id"),
+		},
+		{
+			name:    "legacy w3c trace id",
+			header:  map[string]string{HeaderTraceId: "4BF92F3577B34DA6A3CE929D0E0E4736"},
+			traceId: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:   "invalid traceparent falls back to new trace",
+			header: map[string]string{traceid.HeaderTraceparent: "garbage"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range c.header {
+				r.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(context.Background(), &responseWriter{ResponseWriter: rec}, &Request{Request: r})
+
+			if c.traceId != "" && gotTraceId != c.traceId {
+				t.Errorf("trace id = %q, want %q", gotTraceId, c.traceId)
+			}
+			if gotTraceId == "" || rec.Header().Get(HeaderTraceId) != gotTraceId {
+				t.Errorf("X-Trace-Id = %q, trace id = %q", rec.Header().Get(HeaderTraceId), gotTraceId)
+			}
+			// 日志里的 trace id 与向下游传播的是同一个
+			if gotSpanCtx.TraceId != gotTraceId || !traceid.IsValidTraceId(gotSpanCtx.TraceId) ||
+				gotSpanCtx.SpanId != c.spanId || gotSpanCtx.State != c.state || !gotSpanCtx.Remote {
+				t.Errorf("unexpected span context: %+v", gotSpanCtx)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package kate
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/stn81/kate/trace"
+)
+
+// Tracing implements the tracing middleware, a server span named "METHOD route" is opened per request.
+// It should be placed after TraceId to continue the trace of the caller.
+var Tracing = MiddlewareFunc(tracingFunc)
+
+func tracingFunc(h ContextHandler) ContextHandler {
+	f := func(ctx context.Context, w ResponseWriter, r *Request) {
+		route := r.Route
+		if route == "" {
+			route = routeUnknown
+		}
+
+		ctx, span := trace.StartSpan(ctx, r.Method+" "+route,
+			trace.WithKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				trace.String("http.request.method", r.Method),
+				trace.String("http.route", route),
+				trace.String("url.path", r.URL.Path),
+			),
+		)
+
+		recorder := &bodyRecorder{ResponseWriter: w, limit: errnoHeadLen}
+		defer func() {
+			if p := recover(); p != nil {
+				span.SetStatus(trace.StatusError, fmt.Sprint(p))
+				span.End()
+				panic(p)
+			}
+
+			// 未写响应时 net/http 发送 200
+			status := cmp.Or(w.StatusCode(), http.StatusOK)
+			span.SetAttributes(trace.Int("http.response.status_code", status))
+			if errno := errnoOf(recorder.body.Bytes()); errno != "" {
+				span.SetAttributes(trace.String("kate.errno", errno))
+			}
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(trace.StatusError, http.StatusText(status))
+			}
+			span.End()
+		}()
+
+		h.ServeHTTP(ctx, recorder, r)
+	}
+	return ContextHandlerFunc(f)
+}
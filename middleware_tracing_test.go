@@ -0,0 +1,100 @@
+package kate
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stn81/kate/trace"
+	"github.com/stn81/kate/traceid"
+	"go.uber.org/zap"
+)
+
+type spanRecorder []*trace.SpanData
+
+func (r *spanRecorder) ExportSpan(span *trace.SpanData) {
+	*r = append(*r, span)
+}
+
+func (r *spanRecorder) Shutdown(context.Context) error {
+	return nil
+}
+
+func TestTracing(t *testing.T) {
+	var rec spanRecorder
+	trace.SetExporter(&rec)
+	defer trace.SetExporter(nil)
+
+	router := NewRESTRouter(context.Background(), zap.NewNop())
+	chain := NewChain(TraceId, Tracing)
+
+	var inner traceid.SpanContext
+	router.GET("/users/:id", chain.ThenFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		_, span := trace.StartSpan(ctx, "db.query")
+		inner = span.SpanContext()
+		span.End()
+		(&RESTHandler{}).Error(ctx, w, ErrOverloaded)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set(traceid.HeaderTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(rec) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(rec))
+	}
+	db, server := rec[0], rec[1]
+
+	if server.Name != "GET /users/:id" || server.Kind != trace.SpanKindServer ||
+		server.TraceId != "4bf92f3577b34da6a3ce929d0e0e4736" || server.ParentSpanId != "00f067aa0ba902b7" {
+		t.Errorf("unexpected server span: %+v", server)
+	}
+	if db.ParentSpanId != server.SpanId || inner.SpanId != db.SpanId {
+		t.Errorf("handler span not child of server span: %+v", db)
+	}
+	if server.StatusCode != trace.StatusError {
+		t.Errorf("status = %v, want error", server.StatusCode)
+	}
+
+	attrs := make(map[string]any)
+	for _, attr := range server.Attributes {
+		attrs[attr.Key] = attr.Value
+	}
+	if attrs["http.response.status_code"] != int64(http.StatusServiceUnavailable) || attrs["kate.errno"] != "-3" {
+		t.Errorf("unexpected attributes: %v", attrs)
+	}
+}
+
+func TestTracing_ResponseAttributes(t *testing.T) {
+	var rec spanRecorder
+	trace.SetExporter(&rec)
+	defer trace.SetExporter(nil)
+
+	router := NewRESTRouter(context.Background(), zap.NewNop())
+	router.GET("/noop", Tracing.Proxy(ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {})))
+	router.GET("/stream", Tracing.Proxy(ContextHandlerFunc(func(ctx context.Context, w ResponseWriter, r *Request) {
+		_, _ = w.Write([]byte(`{"errno":-3,`))
+		_, _ = w.Write([]byte(`"errmsg":"busy"}`))
+	})))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/noop", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/stream", nil))
+
+	if len(rec) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(rec))
+	}
+	attrs := make([]map[string]any, len(rec))
+	for i, span := range rec {
+		attrs[i] = make(map[string]any)
+		for _, attr := range span.Attributes {
+			attrs[i][attr.Key] = attr.Value
+		}
+	}
+	if status := attrs[0]["http.response.status_code"]; status != int64(http.StatusOK) {
+		t.Errorf("http.response.status_code = %v, want 200", status)
+	}
+	// errno 取自整个响应体，而不是最后一次 Write
+	if errno := attrs[1]["kate.errno"]; errno != "-3" {
+		t.Errorf("kate.errno = %v, want -3", errno)
+	}
+}
@@ -0,0 +1,295 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stn81/kate/log"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrNotFound is returned by loaders for absent values, and by the cache for negative entries
+	ErrNotFound = errors.New("rdb: not found")
+	// ErrCacheMiss is returned by Cache.Get when the key is not cached
+	ErrCacheMiss = errors.New("rdb: cache miss")
+)
+
+// DefaultCacheJitter is the default ratio of TTL jitter
+const DefaultCacheJitter = 0.1
+
+// notFoundMarker is the value of negative entries, it is never a valid JSON document
+var notFoundMarker = []byte("\x00kate:not_found")
+
+// Codec encodes the cached values
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec is the JSON codec
+type JSONCodec struct{}
+
+// Marshal implements Codec
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal implements Codec
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// CacheConfig is the config of Cache
+type CacheConfig struct {
+	Prefix      string        // prepended to every key
+	Codec       Codec         // default JSONCodec
+	NotFoundTTL time.Duration // ttl of negative entries, 0 disables negative caching
+	Jitter      float64       // ttl is randomly extended by up to ttl*Jitter, default DefaultCacheJitter, <0 disables
+}
+
+// Cache is a typed read-through cache on redis
+type Cache[T any] struct {
+	client Client
+	conf   CacheConfig
+	flight flightGroup[T]
+}
+
+// NewCache create a cache storing T in client
+func NewCache[T any](client Client, conf *CacheConfig) *Cache[T] {
+	c := &Cache[T]{client: client, conf: *conf}
+	if c.conf.Codec == nil {
+		c.conf.Codec = JSONCodec{}
+	}
+	if c.conf.Jitter == 0 {
+		c.conf.Jitter = DefaultCacheJitter
+	}
+	return c
+}
+
+// Get return the cached value of key, ErrCacheMiss if not cached, ErrNotFound for a negative entry
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
+	var v T
+
+	data, err := c.client.Get(ctx, c.conf.Prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return v, ErrCacheMiss
+	}
+	if err != nil {
+		return v, err
+	}
+	return c.decode(data)
+}
+
+// Set cache v with ttl, 0 ttl means no expiration
+func (c *Cache[T]) Set(ctx context.Context, key string, v T, ttl time.Duration) error {
+	data, err := c.conf.Codec.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("rdb: encode cache value: %w", err)
+	}
+	return c.client.Set(ctx, c.conf.Prefix+key, data, c.jitter(ttl)).Err()
+}
+
+// Delete remove the keys
+func (c *Cache[T]) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	// 逐个删除，避免 cluster 模式下跨 slot 的 DEL
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, c.conf.Prefix+key)
+		}
+		return nil
+	})
+	return err
+}
+
+// GetOrLoad return the cached value of key, on miss the value is loaded and cached with ttl.
+// A loader returning ErrNotFound is cached as a negative entry if NotFoundTTL is set.
+// Concurrent loads of the same key in the process share one loader call.
+// Redis failures are logged and the value is loaded from the loader.
+func (c *Cache[T]) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader func(ctx context.Context) (T, error)) (T, error) {
+	v, err := c.Get(ctx, key)
+	switch {
+	case err == nil || errors.Is(err, ErrNotFound):
+		return v, err
+	case !errors.Is(err, ErrCacheMiss):
+		log.GetLogger(ctx).Warn("read cache failed", zap.String("key", key), zap.Error(err))
+	}
+
+	return c.flight.Do(ctx, key, func() (T, error) {
+		v, err := loader(ctx)
+		switch {
+		case err == nil:
+			if serr := c.Set(ctx, key, v, ttl); serr != nil {
+				log.GetLogger(ctx).Warn("write cache failed", zap.String("key", key), zap.Error(serr))
+			}
+		case errors.Is(err, ErrNotFound):
+			c.setNotFound(ctx, key)
+		}
+		return v, err
+	})
+}
+
+// MGetOrLoad return the values of keys, the missed keys are loaded in one loader call and cached with ttl.
+// Keys absent from the loader result are not found, they are omitted from the result and cached as
+// negative entries if NotFoundTTL is set. Batch loads are not deduplicated across callers.
+func (c *Cache[T]) MGetOrLoad(ctx context.Context, keys []string, ttl time.Duration,
+	loader func(ctx context.Context, keys []string) (map[string]T, error)) (map[string]T, error) {
+	var (
+		result = make(map[string]T, len(keys))
+		missed []string
+		logger = log.GetLogger(ctx)
+	)
+
+	// 用 pipeline 逐个 GET，避免 cluster 模式下跨 slot 的 MGET
+	cmds := make([]*redis.StringCmd, len(keys))
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Get(ctx, c.conf.Prefix+key)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		logger.Warn("read cache failed", zap.Int("keys", len(keys)), zap.Error(err))
+	}
+
+	for i, key := range keys {
+		data, err := cmds[i].Bytes()
+		if err != nil {
+			missed = append(missed, key)
+			continue
+		}
+
+		v, err := c.decode(data)
+		switch {
+		case err == nil:
+			result[key] = v
+		case errors.Is(err, ErrNotFound):
+		default:
+			logger.Warn("decode cache failed", zap.String("key", key), zap.Error(err))
+			missed = append(missed, key)
+		}
+	}
+
+	if len(missed) == 0 {
+		return result, nil
+	}
+
+	loaded, err := loader(ctx, missed)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range missed {
+			v, ok := loaded[key]
+			if !ok {
+				if c.conf.NotFoundTTL > 0 {
+					pipe.Set(ctx, c.conf.Prefix+key, notFoundMarker, c.jitter(c.conf.NotFoundTTL))
+				}
+				continue
+			}
+
+			result[key] = v
+			data, err := c.conf.Codec.Marshal(v)
+			if err != nil {
+				logger.Warn("encode cache value failed", zap.String("key", key), zap.Error(err))
+				continue
+			}
+			pipe.Set(ctx, c.conf.Prefix+key, data, c.jitter(ttl))
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Warn("write cache failed", zap.Int("keys", len(missed)), zap.Error(err))
+	}
+	return result, nil
+}
+
+func (c *Cache[T]) decode(data []byte) (T, error) {
+	var v T
+	if string(data) == string(notFoundMarker) {
+		return v, ErrNotFound
+	}
+	if err := c.conf.Codec.Unmarshal(data, &v); err != nil {
+		return v, fmt.Errorf("rdb: decode cache value: %w", err)
+	}
+	return v, nil
+}
+
+func (c *Cache[T]) setNotFound(ctx context.Context, key string) {
+	if c.conf.NotFoundTTL <= 0 {
+		return
+	}
+	if err := c.client.Set(ctx, c.conf.Prefix+key, notFoundMarker, c.jitter(c.conf.NotFoundTTL)).Err(); err != nil {
+		log.GetLogger(ctx).Warn("write cache failed", zap.String("key", key), zap.Error(err))
+	}
+}
+
+// jitter extend ttl randomly so that entries written together do not expire together
+func (c *Cache[T]) jitter(ttl time.Duration) time.Duration {
+	if ttl <= 0 || c.conf.Jitter <= 0 {
+		return ttl
+	}
+	if extra := time.Duration(float64(ttl) * c.conf.Jitter); extra > 0 {
+		ttl += rand.N(extra + 1)
+	}
+	return ttl
+}
+
+// flightGroup deduplicates concurrent calls of the same key
+type flightGroup[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall[T]
+}
+
+type flightCall[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+}
+
+// Do call f once for concurrent callers of key, a waiter returns early if its ctx is done
+func (g *flightGroup[T]) Do(ctx context.Context, key string, f func() (T, error)) (T, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall[T])
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.val, call.err
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+
+	call := &flightCall[T]{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		if p := recover(); p != nil {
+			call.err = fmt.Errorf("rdb: load %s panic: %v", key, p)
+			defer panic(p)
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.val, call.err = f()
+	return call.val, call.err
+}
@@ -0,0 +1,160 @@
+package rdb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+type user struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func newTestCache(t *testing.T) (*miniredis.Miniredis, *Cache[user]) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return mr, NewCache[user](client, &CacheConfig{Prefix: "user:", NotFoundTTL: time.Minute})
+}
+
+func TestCache_GetSetDelete(t *testing.T) {
+	mr, cache := newTestCache(t)
+	ctx := context.Background()
+
+	if _, err := cache.Get(ctx, "1"); !errors.Is(err, ErrCacheMiss) {
+		t.Fatalf("Get() on miss = %v", err)
+	}
+	if err := cache.Set(ctx, "1", user{1, "kate"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := cache.Get(ctx, "1"); err != nil || u.Name != "kate" {
+		t.Errorf("Get() = %+v, %v", u, err)
+	}
+	if ttl := mr.TTL("user:1"); ttl < time.Hour || ttl > time.Hour+6*time.Minute {
+		t.Errorf("ttl = %v, want within jitter", ttl)
+	}
+	if err := cache.Delete(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists("user:1") {
+		t.Error("key not deleted")
+	}
+}
+
+func TestCache_GetOrLoad(t *testing.T) {
+	mr, cache := newTestCache(t)
+	ctx := context.Background()
+
+	var (
+		loads   atomic.Int32
+		release = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+	loader := func(ctx context.Context) (user, error) {
+		loads.Add(1)
+		<-release
+		return user{2, "loaded"}, nil
+	}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if u, err := cache.GetOrLoad(ctx, "2", time.Hour, loader); err != nil || u.Name != "loaded" {
+				t.Errorf("GetOrLoad() = %+v, %v", u, err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads.Load() != 1 {
+		t.Errorf("loader called %d times, want 1", loads.Load())
+	}
+	if !mr.Exists("user:2") {
+		t.Error("loaded value not cached")
+	}
+
+	// 已缓存，不再调用 loader
+	if _, err := cache.GetOrLoad(ctx, "2", time.Hour, loader); err != nil || loads.Load() != 1 {
+		t.Errorf("cached value should be used, loads = %d, err = %v", loads.Load(), err)
+	}
+}
+
+func TestCache_NegativeCaching(t *testing.T) {
+	mr, cache := newTestCache(t)
+	ctx := context.Background()
+
+	var loads int
+	loader := func(ctx context.Context) (user, error) {
+		loads++
+		return user{}, ErrNotFound
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.GetOrLoad(ctx, "404", time.Hour, loader); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("GetOrLoad() = %v, want ErrNotFound", err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("loader called %d times, want 1", loads)
+	}
+	if ttl := mr.TTL("user:404"); ttl < time.Minute || ttl > time.Minute+6*time.Second {
+		t.Errorf("negative ttl = %v", ttl)
+	}
+}
+
+func TestCache_MGetOrLoad(t *testing.T) {
+	mr, cache := newTestCache(t)
+	ctx := context.Background()
+
+	_ = cache.Set(ctx, "1", user{1, "cached"}, time.Hour)
+
+	var requested []string
+	loader := func(ctx context.Context, keys []string) (map[string]user, error) {
+		requested = keys
+		return map[string]user{"2": {2, "loaded"}}, nil
+	}
+
+	got, err := cache.MGetOrLoad(ctx, []string{"1", "2", "3"}, time.Hour, loader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["1"].Name != "cached" || got["2"].Name != "loaded" {
+		t.Errorf("MGetOrLoad() = %+v", got)
+	}
+	if len(requested) != 2 || requested[0] != "2" || requested[1] != "3" {
+		t.Errorf("loader keys = %v", requested)
+	}
+	if !mr.Exists("user:2") || !mr.Exists("user:3") {
+		t.Error("loaded and not-found keys should be cached")
+	}
+
+	// 第二次全部命中（含负缓存），不再调用 loader
+	requested = nil
+	if got, err = cache.MGetOrLoad(ctx, []string{"1", "2", "3"}, time.Hour, loader); err != nil || len(got) != 2 || requested != nil {
+		t.Errorf("MGetOrLoad() = %+v, %v, loader keys = %v", got, err, requested)
+	}
+}
+
+func TestCache_RedisDown(t *testing.T) {
+	mr, cache := newTestCache(t)
+	mr.Close()
+
+	u, err := cache.GetOrLoad(context.Background(), "1", time.Hour, func(ctx context.Context) (user, error) {
+		return user{1, "db"}, nil
+	})
+	if err != nil || u.Name != "db" {
+		t.Errorf("GetOrLoad() should fall back to loader: %+v, %v", u, err)
+	}
+}
@@ -0,0 +1,163 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stn81/kate/log"
+	"go.uber.org/zap"
+)
+
+// eventBusPingInterval is the idle time before the subscription connection is health checked
+var eventBusPingInterval = 30 * time.Second
+
+// eventBusRetryDelay is the delay after a receive failure, before the connection is re-established
+var eventBusRetryDelay = 500 * time.Millisecond
+
+// EventHandler handles the events of channel, the event is decoded from the JSON payload
+type EventHandler[T any] func(ctx context.Context, channel string, event T)
+
+// Subscriber is the redis client which can subscribe, it is kept out of Client so that
+// the custom implementations of Client are not broken. The clients created by this package
+// implement it, e.g. rdb.Get().(rdb.Subscriber).
+type Subscriber interface {
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+	PSubscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+// Subscription is a running subscription of the event bus
+type Subscription struct {
+	pubsub *redis.PubSub
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Close stop the subscription and wait the running handler to return
+func (s *Subscription) Close() error {
+	s.cancel()
+	err := s.pubsub.Close()
+	<-s.done
+	return err
+}
+
+// Publish publish event to channel as JSON, return the number of receivers
+func Publish[T any](ctx context.Context, client Client, channel string, event T) (int64, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("rdb: encode event: %w", err)
+	}
+	return client.Publish(ctx, channel, payload).Result()
+}
+
+// Subscribe subscribe the channels, events are handled one by one in order.
+// The subscription is re-established after reconnecting, events published while disconnected are lost.
+// Handler panics are recovered, the handler logger is from ctx.
+func Subscribe[T any](ctx context.Context, client Subscriber, handler EventHandler[T], channels ...string) (*Subscription, error) {
+	return subscribe(ctx, client.Subscribe(ctx), handler, false, channels)
+}
+
+// PSubscribe subscribe the channel patterns, see Subscribe
+func PSubscribe[T any](ctx context.Context, client Subscriber, handler EventHandler[T], patterns ...string) (*Subscription, error) {
+	return subscribe(ctx, client.PSubscribe(ctx), handler, true, patterns)
+}
+
+func subscribe[T any](ctx context.Context, pubsub *redis.PubSub, handler EventHandler[T], pattern bool, channels []string) (*Subscription, error) {
+	var err error
+	if pattern {
+		err = pubsub.PSubscribe(ctx, channels...)
+	} else {
+		err = pubsub.Subscribe(ctx, channels...)
+	}
+	if err == nil {
+		// 等待订阅确认，保证返回后发布的事件不会丢失
+		_, err = pubsub.Receive(ctx)
+	}
+	if err != nil {
+		_ = pubsub.Close()
+		return nil, fmt.Errorf("rdb: subscribe %v: %w", channels, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Subscription{
+		pubsub: pubsub,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	logger := log.GetLogger(ctx).With(zap.Strings("channels", channels))
+	go func() {
+		defer close(s.done)
+		receiveEvents(ctx, pubsub, handler, logger)
+	}()
+	return s, nil
+}
+
+func receiveEvents[T any](ctx context.Context, pubsub *redis.PubSub, handler EventHandler[T], logger *zap.Logger) {
+	broken := false
+	for ctx.Err() == nil {
+		msg, err := pubsub.ReceiveTimeout(ctx, eventBusPingInterval)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, redis.ErrClosed) {
+				return
+			}
+
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				// 空闲超时：ping 探活，连接失效时下次 Receive 会重连并重新订阅
+				_ = pubsub.Ping(ctx)
+				continue
+			}
+
+			if !broken {
+				logger.Warn("receive event failed, resubscribing", zap.Error(err))
+				broken = true
+			}
+			sleepContext(ctx, eventBusRetryDelay)
+			continue
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			if broken {
+				logger.Info("resubscribed", zap.String("channel", msg.Channel))
+				broken = false
+			}
+		case *redis.Message:
+			broken = false
+			handleEvent(ctx, msg, handler, logger)
+		}
+	}
+}
+
+func handleEvent[T any](ctx context.Context, msg *redis.Message, handler EventHandler[T], logger *zap.Logger) {
+	logger = logger.With(zap.String("channel", msg.Channel))
+	ctx = log.ToContext(ctx, logger)
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("event handler panic", zap.Any("error", r), zap.Stack("stack"))
+		}
+	}()
+
+	var event T
+	if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+		logger.Error("decode event failed", zap.String("payload", msg.Payload), zap.Error(err))
+		return
+	}
+	handler(ctx, msg.Channel, event)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
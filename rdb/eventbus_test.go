@@ -0,0 +1,155 @@
+package rdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+type invalidation struct {
+	Key string `json:"key"`
+}
+
+type received struct {
+	channel string
+	event   invalidation
+}
+
+func newEventBusClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return mr, client
+}
+
+func expectEvent(t *testing.T, ch <-chan received, channel, key string) {
+	t.Helper()
+	select {
+	case got := <-ch:
+		if got.channel != channel || got.event.Key != key {
+			t.Errorf("got %+v, want %s/%s", got, channel, key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("event %s/%s not received", channel, key)
+	}
+}
+
+func TestEventBus_Subscribe(t *testing.T) {
+	_, client := newEventBusClient(t)
+	ctx := context.Background()
+
+	ch := make(chan received, 4)
+	sub, err := Subscribe(ctx, client, func(ctx context.Context, channel string, event invalidation) {
+		if event.Key == "panic" {
+			panic("broken handler")
+		}
+		ch <- received{channel, event}
+	}, "cache.invalidate")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"panic", "user:1"} {
+		if n, err := Publish(ctx, client, "cache.invalidate", invalidation{key}); err != nil || n != 1 {
+			t.Fatalf("Publish() = %d, %v", n, err)
+		}
+	}
+	// 非 JSON 负载被丢弃，不影响后续事件
+	_ = client.Publish(ctx, "cache.invalidate", "not json").Err()
+	_, _ = Publish(ctx, client, "cache.invalidate", invalidation{"user:2"})
+
+	expectEvent(t, ch, "cache.invalidate", "user:1")
+	expectEvent(t, ch, "cache.invalidate", "user:2")
+
+	if err = sub.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// 服务端异步感知连接关闭
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		n, _ := Publish(ctx, client, "cache.invalidate", invalidation{"user:3"})
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("receivers after Close = %d, want 0", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case got := <-ch:
+		t.Errorf("event received after Close: %+v", got)
+	default:
+	}
+}
+
+func TestEventBus_PSubscribe(t *testing.T) {
+	_, client := newEventBusClient(t)
+	ctx := context.Background()
+
+	ch := make(chan received, 4)
+	sub, err := PSubscribe(ctx, client, func(ctx context.Context, channel string, event invalidation) {
+		ch <- received{channel, event}
+	}, "config.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	_, _ = Publish(ctx, client, "config.feature", invalidation{"flag"})
+	_, _ = Publish(ctx, client, "other", invalidation{"ignored"})
+	_, _ = Publish(ctx, client, "config.limit", invalidation{"qps"})
+
+	expectEvent(t, ch, "config.feature", "flag")
+	expectEvent(t, ch, "config.limit", "qps")
+}
+
+func TestEventBus_Resubscribe(t *testing.T) {
+	retryDelay := eventBusRetryDelay
+	eventBusRetryDelay = 10 * time.Millisecond
+	defer func() {
+		eventBusRetryDelay = retryDelay
+	}()
+
+	mr, client := newEventBusClient(t)
+	ctx := context.Background()
+
+	ch := make(chan received, 4)
+	sub, err := Subscribe(ctx, client, func(ctx context.Context, channel string, event invalidation) {
+		ch <- received{channel, event}
+	}, "cache.invalidate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	mr.Close()
+	time.Sleep(50 * time.Millisecond)
+	if err = mr.Restart(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重连后重新订阅
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if n, _ := Publish(ctx, client, "cache.invalidate", invalidation{"after"}); n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("not resubscribed after reconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	expectEvent(t, ch, "cache.invalidate", "after")
+}
+
+// 内置客户端都能订阅，Client 接口本身不要求
+var (
+	_ Subscriber = (*redis.Client)(nil)
+	_ Subscriber = (*redis.ClusterClient)(nil)
+)
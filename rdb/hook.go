@@ -0,0 +1,199 @@
+package rdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stn81/kate/metrics"
+	"github.com/stn81/kate/trace"
+	"github.com/stn81/kate/traceid"
+	"go.uber.org/zap"
+)
+
+// pipelineName is the command label of pipelines
+const pipelineName = "pipeline"
+
+var (
+	commandsTotal = metrics.Default.Counter("redis_commands_total",
+		"Total number of redis commands.", "instance", "command", "result")
+	commandDurations = metrics.Default.Histogram("redis_command_duration_seconds",
+		"Latency of redis commands and pipelines in seconds.",
+		[]float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}, "instance", "command")
+)
+
+// hook implements redis.Hook for slow log, metrics and tracing
+type hook struct {
+	name          string
+	slowThreshold time.Duration
+	metrics       bool
+	tracing       bool
+	logger        *zap.Logger
+}
+
+// NewHook create the redis hook of instance name configured by conf:
+//   - commands slower than SlowThreshold are logged with redacted args and the trace id
+//   - MetricsEnabled records redis_commands_total and redis_command_duration_seconds to metrics.Default
+//   - TracingEnabled starts a client span per command or pipeline
+func NewHook(name string, conf *Config) redis.Hook {
+	h := &hook{
+		name:          name,
+		slowThreshold: conf.SlowThreshold,
+		metrics:       conf.MetricsEnabled,
+		tracing:       conf.TracingEnabled,
+		logger:        conf.Logger,
+	}
+	if h.logger == nil {
+		h.logger = zap.NewNop()
+	}
+	h.logger = h.logger.With(zap.String("redis", name))
+	return h
+}
+
+func hookEnabled(conf *Config) bool {
+	return conf.SlowThreshold > 0 || conf.MetricsEnabled || conf.TracingEnabled
+}
+
+// DialHook implements redis.Hook
+func (h *hook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+// ProcessHook implements redis.Hook
+func (h *hook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		var span *trace.Span
+		if h.tracing {
+			ctx, span = trace.StartSpan(ctx, "redis "+cmd.Name(),
+				trace.WithKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					trace.String("db.system", "redis"),
+					trace.String("db.operation", cmd.Name()),
+					trace.String("db.statement", redact(cmd.Args())),
+				),
+			)
+		}
+
+		start := time.Now()
+		err := next(ctx, cmd)
+		elapsed := time.Since(start)
+
+		if span != nil {
+			if isError(err) {
+				span.RecordError(err)
+			}
+			span.End()
+		}
+		if h.metrics {
+			commandsTotal.WithLabelValues(h.name, cmd.Name(), result(err)).Inc()
+			commandDurations.WithLabelValues(h.name, cmd.Name()).Observe(elapsed.Seconds())
+		}
+		if h.slowThreshold > 0 && elapsed >= h.slowThreshold {
+			h.logger.Warn("slow redis command",
+				zap.String("trace_id", traceid.Extract(ctx)),
+				zap.String("cmd", redact(cmd.Args())),
+				zap.Duration("elapsed", elapsed),
+				zap.Error(err),
+			)
+		}
+		return err
+	}
+}
+
+// ProcessPipelineHook implements redis.Hook
+func (h *hook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		var span *trace.Span
+		if h.tracing {
+			ctx, span = trace.StartSpan(ctx, "redis "+pipelineName,
+				trace.WithKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					trace.String("db.system", "redis"),
+					trace.String("db.operation", pipelineName),
+					trace.Int("db.redis.pipeline_length", len(cmds)),
+				),
+			)
+		}
+
+		start := time.Now()
+		err := next(ctx, cmds)
+		elapsed := time.Since(start)
+
+		if span != nil {
+			if isError(err) {
+				span.RecordError(err)
+			}
+			span.End()
+		}
+		if h.metrics {
+			for _, cmd := range cmds {
+				commandsTotal.WithLabelValues(h.name, cmd.Name(), result(cmd.Err())).Inc()
+			}
+			commandDurations.WithLabelValues(h.name, pipelineName).Observe(elapsed.Seconds())
+		}
+		if h.slowThreshold > 0 && elapsed >= h.slowThreshold {
+			statements := make([]string, len(cmds))
+			for i, cmd := range cmds {
+				statements[i] = redact(cmd.Args())
+			}
+			h.logger.Warn("slow redis pipeline",
+				zap.String("trace_id", traceid.Extract(ctx)),
+				zap.Strings("cmds", statements),
+				zap.Duration("elapsed", elapsed),
+				zap.Error(err),
+			)
+		}
+		return err
+	}
+}
+
+// isError check whether err is a failure, redis.Nil is a normal miss
+func isError(err error) bool {
+	return err != nil && !errors.Is(err, redis.Nil)
+}
+
+func result(err error) string {
+	if isError(err) {
+		return "error"
+	}
+	return "ok"
+}
+
+// sensitiveCommands have every argument redacted
+var sensitiveCommands = map[string]bool{
+	"auth":  true,
+	"hello": true,
+	"acl":   true,
+}
+
+// maxRedactedArgs is the max args rendered by redact
+const maxRedactedArgs = 8
+
+// redact render the command with the key only, the values are never logged, e.g. "set user:1 ?"
+func redact(args []any) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	var (
+		sb   strings.Builder
+		name = strings.ToLower(fmt.Sprint(args[0]))
+	)
+	sb.WriteString(name)
+	for i := 1; i < len(args); i++ {
+		if i > maxRedactedArgs {
+			fmt.Fprintf(&sb, " ...(%d args)", len(args)-1)
+			break
+		}
+		sb.WriteByte(' ')
+		if i == 1 && !sensitiveCommands[name] {
+			sb.WriteString(fmt.Sprint(args[i]))
+		} else {
+			sb.WriteByte('?')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,112 @@
+package rdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stn81/kate/metrics"
+	"github.com/stn81/kate/trace"
+	"github.com/stn81/kate/traceid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type spanRecorder []*trace.SpanData
+
+func (r *spanRecorder) ExportSpan(span *trace.SpanData) {
+	*r = append(*r, span)
+}
+
+func (r *spanRecorder) Shutdown(context.Context) error {
+	return nil
+}
+
+func TestHook(t *testing.T) {
+	mr := miniredis.RunT(t)
+	core, logs := observer.New(zap.WarnLevel)
+
+	var spans spanRecorder
+	trace.SetExporter(&spans)
+	defer trace.SetExporter(nil)
+
+	Init(&Config{
+		Addrs:          []string{mr.Addr()},
+		SlowThreshold:  time.Nanosecond,
+		MetricsEnabled: true,
+		TracingEnabled: true,
+		Logger:         zap.New(core),
+	})
+	defer Uninit()
+
+	ctx := traceid.ToContext(context.Background(), "trace-1")
+	client := Get()
+
+	if err := client.Set(ctx, "user:1", "secret", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	_ = client.Get(ctx, "user:404").Err()
+
+	pipe := client.Pipeline()
+	pipe.Incr(ctx, "counter")
+	pipe.HSet(ctx, "user:2", "password", "secret")
+	if _, err := pipe.Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := logs.All()
+	if len(entries) != 3 {
+		t.Fatalf("logged %d slow entries, want 3", len(entries))
+	}
+	for _, entry := range entries {
+		fields := entry.ContextMap()
+		if fields["trace_id"] != "trace-1" {
+			t.Errorf("trace id missing: %v", fields)
+		}
+		if strings.Contains(fmt.Sprint(fields), "secret") {
+			t.Errorf("args not redacted: %v", fields)
+		}
+	}
+	if cmd := entries[0].ContextMap()["cmd"]; cmd != "set user:1 ?" {
+		t.Errorf("cmd = %q", cmd)
+	}
+
+	var sb strings.Builder
+	_, _ = metrics.Default.WriteTo(&sb)
+	for _, want := range []string{
+		`redis_commands_total{instance="default",command="get",result="ok"} 1`,
+		`redis_commands_total{instance="default",command="hset",result="ok"} 1`,
+		`redis_command_duration_seconds_count{instance="default",command="pipeline"} 1`,
+	} {
+		if !strings.Contains(sb.String(), want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name)
+		if span.StatusCode == trace.StatusError {
+			t.Errorf("span %s should not fail, redis.Nil is a miss", span.Name)
+		}
+	}
+	if strings.Join(names, ",") != "redis set,redis get,redis pipeline" {
+		t.Errorf("spans = %v", names)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	cases := map[string][]any{
+		"get k":                             {"GET", "k"},
+		"auth ? ?":                          {"auth", "user", "pass"},
+		"mset a ? ? ? ? ? ? ? ...(10 args)": {"mset", "a", 1, "b", 2, "c", 3, "d", 4, "e", 5},
+	}
+	for want, args := range cases {
+		if got := redact(args); got != want {
+			t.Errorf("redact(%v) = %q, want %q", args, got, want)
+		}
+	}
+}
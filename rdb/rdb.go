@@ -2,9 +2,12 @@ package rdb
 
 import (
 	"context"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
 )
 
 const (
@@ -24,43 +27,76 @@ type Client interface {
 	Close() error
 }
 
-// Config defines the redis config
+// Config defines the redis config.
+// With SentinelEnabled, Addrs are the sentinel addresses and MasterName is the monitored master.
 type Config struct {
-	Addrs           []string
-	DB              int
-	Username        string
-	Password        string
-	ClusterEnabled  bool
-	ReadOnly        bool
-	RouteMode       string
-	MaxRedirects    int
-	MaxRetries      int
-	MinRetryBackoff time.Duration
-	MaxRetryBackoff time.Duration
-	ConnectTimeout  time.Duration
-	ReadTimeout     time.Duration
-	WriteTimeout    time.Duration
-	PoolSize        int
-	PoolTimeout     time.Duration
-	MinIdleConns    int
-	MaxIdleConns    int
-	MaxActiveConns  int
-	ConnMaxIdleTime time.Duration
-	ConnMaxLifetime time.Duration
+	Addrs            []string
+	DB               int
+	Username         string
+	Password         string
+	ClusterEnabled   bool
+	SentinelEnabled  bool
+	MasterName       string
+	SentinelUsername string
+	SentinelPassword string
+	ReadOnly         bool
+	RouteMode        string
+	MaxRedirects     int
+	MaxRetries       int
+	MinRetryBackoff  time.Duration
+	MaxRetryBackoff  time.Duration
+	ConnectTimeout   time.Duration
+	ReadTimeout      time.Duration
+	WriteTimeout     time.Duration
+	PoolSize         int
+	PoolTimeout      time.Duration
+	MinIdleConns     int
+	MaxIdleConns     int
+	MaxActiveConns   int
+	ConnMaxIdleTime  time.Duration
+	ConnMaxLifetime  time.Duration
+	SlowThreshold    time.Duration // log commands slower than it, 0 disables the slow log
+	MetricsEnabled   bool          // record command metrics to metrics.Default
+	TracingEnabled   bool          // start a span per command
+	Logger           *zap.Logger   // logger of the slow log
 }
 
-var rdb Client
+// DefaultName is the instance name of the default client
+const DefaultName = "default"
 
-// Init initialize the redis cluster instance
+var (
+	mu      sync.RWMutex
+	clients = make(map[string]Client)
+)
+
+// Init initialize the default redis instance
 func Init(conf *Config) {
-	if conf.ClusterEnabled {
-		rdb = newClusterClient(conf)
-	} else {
-		rdb = newClient(conf)
+	InitNamed(DefaultName, conf)
+}
+
+// InitNamed initialize the redis instance of name, the previous instance of the same name is closed
+func InitNamed(name string, conf *Config) {
+	var client Client
+	switch {
+	case conf.SentinelEnabled:
+		client = newFailoverClient(name, conf)
+	case conf.ClusterEnabled:
+		client = newClusterClient(name, conf)
+	default:
+		client = newClient(name, conf)
+	}
+
+	mu.Lock()
+	old := clients[name]
+	clients[name] = client
+	mu.Unlock()
+
+	if old != nil {
+		_ = old.Close()
 	}
 }
 
-func newClient(conf *Config) *redis.Client {
+func newClient(name string, conf *Config) *redis.Client {
 	opt := &redis.Options{
 		Addr:            conf.Addrs[0],
 		DB:              conf.DB,
@@ -80,10 +116,14 @@ func newClient(conf *Config) *redis.Client {
 		PoolTimeout:     conf.PoolTimeout,
 	}
 
-	return redis.NewClient(opt)
+	client := redis.NewClient(opt)
+	if hookEnabled(conf) {
+		client.AddHook(NewHook(name, conf))
+	}
+	return client
 }
 
-func newClusterClient(conf *Config) *redis.ClusterClient {
+func newClusterClient(name string, conf *Config) *redis.ClusterClient {
 	opt := &redis.ClusterOptions{
 		Addrs:           conf.Addrs,
 		Username:        conf.Username,
@@ -112,17 +152,55 @@ func newClusterClient(conf *Config) *redis.ClusterClient {
 		opt.RouteByLatency = true
 	}
 
-	return redis.NewClusterClient(opt)
+	client := redis.NewClusterClient(opt)
+	if hookEnabled(conf) {
+		client.AddHook(NewHook(name, conf))
+	}
+	return client
 }
 
-// Uninit do the clean up for the global RedisConnectionManager instance
+// Uninit close all the redis instances
 func Uninit() {
-	if rdb != nil {
-		_ = rdb.Close()
+	mu.Lock()
+	all := clients
+	clients = make(map[string]Client)
+	mu.Unlock()
+
+	for _, client := range all {
+		_ = client.Close()
 	}
 }
 
-// Get return the rdb client instance
+// Get return the default redis instance, nil if not initialized
 func Get() Client {
-	return rdb
+	return GetNamed(DefaultName)
+}
+
+// GetNamed return the redis instance of name, nil if not initialized
+func GetNamed(name string) Client {
+	mu.RLock()
+	defer mu.RUnlock()
+	return clients[name]
+}
+
+// Names return the names of all the redis instances in order
+func Names() []string {
+	mu.RLock()
+	names := make([]string, 0, len(clients))
+	for name := range clients {
+		names = append(names, name)
+	}
+	mu.RUnlock()
+
+	sort.Strings(names)
+	return names
+}
+
+// Range calls f for each redis instance in name order, it stops if f returns false
+func Range(f func(name string, client Client) bool) {
+	for _, name := range Names() {
+		if client := GetNamed(name); client != nil && !f(name, client) {
+			return
+		}
+	}
 }
@@ -0,0 +1,56 @@
+package rdb
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestInitNamed(t *testing.T) {
+	cache, session := miniredis.RunT(t), miniredis.RunT(t)
+
+	Init(&Config{Addrs: []string{cache.Addr()}})
+	InitNamed("session", &Config{Addrs: []string{session.Addr()}})
+
+	ctx := context.Background()
+	if err := Get().Set(ctx, "k", "cache", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := GetNamed("session").Set(ctx, "k", "session", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := cache.Get("k"); v != "cache" {
+		t.Errorf("default instance wrote to wrong server: %q", v)
+	}
+	if v, _ := session.Get("k"); v != "session" {
+		t.Errorf("named instance wrote to wrong server: %q", v)
+	}
+
+	if GetNamed("queue") != nil {
+		t.Error("unknown instance should be nil")
+	}
+	if got := strings.Join(Names(), ","); got != "default,session" {
+		t.Errorf("Names() = %q", got)
+	}
+
+	var visited []string
+	Range(func(name string, client Client) bool {
+		visited = append(visited, name)
+		return false
+	})
+	if len(visited) != 1 || visited[0] != DefaultName {
+		t.Errorf("Range should stop on false: %v", visited)
+	}
+
+	session1 := GetNamed("session")
+	Uninit()
+
+	if Get() != nil || GetNamed("session") != nil || len(Names()) != 0 {
+		t.Error("instances should be removed by Uninit")
+	}
+	if err := session1.Ping(ctx).Err(); err == nil {
+		t.Error("instances should be closed by Uninit")
+	}
+}
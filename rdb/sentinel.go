@@ -0,0 +1,54 @@
+package rdb
+
+import (
+	"github.com/redis/go-redis/v9"
+)
+
+// newFailoverClient create the client of the master monitored by sentinels at conf.Addrs.
+// RouteModeMasterOnly sends every command to the master, the master_slave modes route
+// read-only commands to both master and replicas, which is backed by a failover cluster client.
+func newFailoverClient(name string, conf *Config) Client {
+	opt := &redis.FailoverOptions{
+		MasterName:       conf.MasterName,
+		SentinelAddrs:    conf.Addrs,
+		SentinelUsername: conf.SentinelUsername,
+		SentinelPassword: conf.SentinelPassword,
+		DB:               conf.DB,
+		Username:         conf.Username,
+		Password:         conf.Password,
+		MaxRetries:       conf.MaxRetries,
+		MinRetryBackoff:  conf.MinRetryBackoff,
+		MaxRetryBackoff:  conf.MaxRetryBackoff,
+		DialTimeout:      conf.ConnectTimeout,
+		ReadTimeout:      conf.ReadTimeout,
+		WriteTimeout:     conf.WriteTimeout,
+		PoolSize:         conf.PoolSize,
+		MinIdleConns:     conf.MinIdleConns,
+		MaxIdleConns:     conf.MaxIdleConns,
+		MaxActiveConns:   conf.MaxActiveConns,
+		ConnMaxIdleTime:  conf.ConnMaxIdleTime,
+		ConnMaxLifetime:  conf.ConnMaxLifetime,
+		PoolTimeout:      conf.PoolTimeout,
+	}
+
+	switch conf.RouteMode {
+	case RouteModeMasterSlaveRandom:
+		opt.RouteRandomly = true
+	case RouteModeMasterSlaveLatency:
+		opt.RouteByLatency = true
+	}
+
+	if opt.RouteRandomly || opt.RouteByLatency {
+		client := redis.NewFailoverClusterClient(opt)
+		if hookEnabled(conf) {
+			client.AddHook(NewHook(name, conf))
+		}
+		return client
+	}
+
+	client := redis.NewFailoverClient(opt)
+	if hookEnabled(conf) {
+		client.AddHook(NewHook(name, conf))
+	}
+	return client
+}
@@ -0,0 +1,212 @@
+package rdb
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeSentinel is an in-process sentinel stand-in speaking RESP2, it answers the commands
+// used by the go-redis failover client and publishes +switch-master on failover.
+type fakeSentinel struct {
+	ln     net.Listener
+	master string
+
+	mu          sync.Mutex
+	masterAddr  string
+	subscribers []net.Conn
+}
+
+func newFakeSentinel(t *testing.T, master, masterAddr string) *fakeSentinel {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSentinel{ln: ln, master: master, masterAddr: masterAddr}
+	go s.serve()
+	t.Cleanup(func() {
+		_ = ln.Close()
+		s.mu.Lock()
+		for _, conn := range s.subscribers {
+			_ = conn.Close()
+		}
+		s.mu.Unlock()
+	})
+	return s
+}
+
+func (s *fakeSentinel) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// failover switch the master to addr and notify the subscribers
+func (s *fakeSentinel) failover(addr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	oldHost, oldPort, _ := net.SplitHostPort(s.masterAddr)
+	newHost, newPort, _ := net.SplitHostPort(addr)
+	s.masterAddr = addr
+
+	payload := strings.Join([]string{s.master, oldHost, oldPort, newHost, newPort}, " ")
+	for _, conn := range s.subscribers {
+		_, _ = io.WriteString(conn, "*3\r\n"+bulk("message")+bulk("+switch-master")+bulk(payload))
+	}
+}
+
+func (s *fakeSentinel) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSentinel) handle(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			_ = conn.Close()
+			return
+		}
+
+		var reply string
+		switch cmd := strings.ToLower(args[0]); {
+		case cmd == "ping":
+			reply = "+PONG\r\n"
+		case cmd == "sentinel" && len(args) > 1 && strings.ToLower(args[1]) == "get-master-addr-by-name":
+			s.mu.Lock()
+			host, port, _ := net.SplitHostPort(s.masterAddr)
+			s.mu.Unlock()
+			reply = "*2\r\n" + bulk(host) + bulk(port)
+		case cmd == "sentinel":
+			reply = "*0\r\n" // no other sentinels or replicas
+		case cmd == "subscribe":
+			for i, channel := range args[1:] {
+				reply += "*3\r\n" + bulk("subscribe") + bulk(channel) + ":" + strconv.Itoa(i+1) + "\r\n"
+			}
+			s.mu.Lock()
+			s.subscribers = append(s.subscribers, conn)
+			s.mu.Unlock()
+		case cmd == "hello":
+			reply = "-ERR unknown command 'hello'\r\n"
+		default:
+			reply = "+OK\r\n"
+		}
+
+		s.mu.Lock()
+		_, err = io.WriteString(conn, reply)
+		s.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected line %q", line)
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func bulk(s string) string {
+	return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+}
+
+func TestSentinel_MasterOnly(t *testing.T) {
+	master, standby := miniredis.RunT(t), miniredis.RunT(t)
+	sentinel := newFakeSentinel(t, "mymaster", master.Addr())
+
+	InitNamed("sentinel", &Config{
+		Addrs:           []string{sentinel.Addr()},
+		SentinelEnabled: true,
+		MasterName:      "mymaster",
+		RouteMode:       RouteModeMasterOnly,
+	})
+	defer Uninit()
+
+	client := GetNamed("sentinel")
+	if _, ok := client.(*redis.Client); !ok {
+		t.Fatalf("master_only should use a failover client, got %T", client)
+	}
+
+	ctx := context.Background()
+	if err := client.Set(ctx, "k", "v1", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := master.Get("k"); v != "v1" {
+		t.Errorf("write not sent to master: %q", v)
+	}
+
+	// 主从切换后，写入应落到新 master
+	sentinel.failover(standby.Addr())
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_ = client.Set(ctx, "k", "v2", 0).Err()
+		if v, _ := standby.Get("k"); v == "v2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("client did not follow the new master")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSentinel_RouteRandomly(t *testing.T) {
+	master := miniredis.RunT(t)
+	sentinel := newFakeSentinel(t, "mymaster", master.Addr())
+
+	InitNamed("sentinel", &Config{
+		Addrs:           []string{sentinel.Addr()},
+		SentinelEnabled: true,
+		MasterName:      "mymaster",
+		RouteMode:       RouteModeMasterSlaveRandom,
+	})
+	defer Uninit()
+
+	client := GetNamed("sentinel")
+	if _, ok := client.(*redis.ClusterClient); !ok {
+		t.Fatalf("master_slave_random should use a failover cluster client, got %T", client)
+	}
+
+	ctx := context.Background()
+	if err := client.Set(ctx, "k", "v", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := client.Get(ctx, "k").Result(); err != nil || v != "v" {
+		t.Errorf("Get() = %q, %v", v, err)
+	}
+}
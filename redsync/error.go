@@ -2,5 +2,13 @@ package redsync
 
 import "errors"
 
-// ErrFailed indicates error happened when acquire the lock
-var ErrFailed = errors.New("redsync: failed to acquire lock")
+var (
+	// ErrFailed indicates error happened when acquire the lock
+	ErrFailed = errors.New("redsync: failed to acquire lock")
+	// ErrLockLost indicates the lock is no longer held by the mutex, it has expired or been taken by others
+	ErrLockLost = errors.New("redsync: lock lost")
+	// ErrUnreachable indicates the quorum is not reached because of redis errors
+	ErrUnreachable = errors.New("redsync: redis unreachable")
+	// ErrStaleToken indicates the write is rejected since a greater fencing token has been written
+	ErrStaleToken = errors.New("redsync: stale fencing token")
+)
@@ -0,0 +1,133 @@
+package redsync
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// FenceKeySuffix is appended to the mutex name to form the key of the fencing counter.
+// In redis cluster the mutex name must contain a hash tag, e.g. "{order:1}", so that both keys are in one slot.
+const FenceKeySuffix = ":fence"
+
+// SetFencing enable fencing tokens: acquiring the lock also increments a per-lock counter in redis,
+// the monotonically increasing value is returned by FencingToken. The counter never expires.
+func SetFencing(enabled bool) Option {
+	return OptionFunc(func(m *Mutex) {
+		m.fencing = enabled
+	})
+}
+
+// FencingToken return the fencing token of the last acquisition, 0 if fencing is disabled or never locked.
+// Pass it to FencedSet or ExecFenced, so that the writes of a holder paused past expiry are rejected.
+func (m *Mutex) FencingToken() int64 {
+	m.nodeMutex.Lock()
+	defer m.nodeMutex.Unlock()
+	return m.fence
+}
+
+var acquireFencedScript = redis.NewScript(`
+	if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+		return redis.call("INCR", KEYS[2])
+	end
+	return 0
+`)
+
+// acquireFenced set the lock and increment the counter atomically, it returns the counter or 0 if not acquired
+func (m *Mutex) acquireFenced(ctx context.Context, pool Pool, token string) (int64, error) {
+	client := pool.Get()
+	return acquireFencedScript.Run(ctx, client, []string{m.name, m.name + FenceKeySuffix},
+		token, int(m.expiry/time.Millisecond)).Int64()
+}
+
+var bumpFenceScript = redis.NewScript(`
+	local current = tonumber(redis.call("GET", KEYS[1]) or "0")
+	if current < tonumber(ARGV[1]) then
+		redis.call("SET", KEYS[1], ARGV[1])
+	end
+	return 1
+`)
+
+// bumpFence raise the counters of all the nodes to fence, so that any later quorum sees a greater value.
+// It is best effort, a node missing the bump only matters if it also misses the next quorum.
+func (m *Mutex) bumpFence(ctx context.Context, fence int64) {
+	for _, pool := range m.pools {
+		_ = bumpFenceScript.Run(ctx, pool.Get(), []string{m.name + FenceKeySuffix}, fence).Err()
+	}
+}
+
+var fencedSetScript = redis.NewScript(`
+	local current = tonumber(redis.call("HGET", KEYS[1], "fence") or "0")
+	if current > tonumber(ARGV[2]) then
+		return 0
+	end
+	redis.call("HSET", KEYS[1], "value", ARGV[1], "fence", ARGV[2])
+	if tonumber(ARGV[3]) > 0 then
+		redis.call("PEXPIRE", KEYS[1], ARGV[3])
+	else
+		redis.call("PERSIST", KEYS[1])
+	end
+	return 1
+`)
+
+// FencedSet store value in the hash key with the fencing token, 0 expiration means no expiration.
+// It returns ErrStaleToken if the key has been written with a greater token.
+func FencedSet(ctx context.Context, client redis.Cmdable, key string, value any, token int64, expiration time.Duration) error {
+	ok, err := fencedSetScript.Run(ctx, client, []string{key}, value, token, int64(expiration/time.Millisecond)).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrStaleToken
+	}
+	return nil
+}
+
+// FencedGet return the value and the fencing token stored by FencedSet, redis.Nil if key does not exist
+func FencedGet(ctx context.Context, client redis.Cmdable, key string) (string, int64, error) {
+	values, err := client.HMGet(ctx, key, "value", "fence").Result()
+	if err != nil {
+		return "", 0, err
+	}
+	value, ok := values[0].(string)
+	if !ok {
+		return "", 0, redis.Nil
+	}
+
+	var fence int64
+	if s, ok := values[1].(string); ok {
+		fence, _ = strconv.ParseInt(s, 10, 64)
+	}
+	return value, fence, nil
+}
+
+// SQLExecer executes a statement, it is implemented by *sql.DB, *sql.Tx, *sqlx.DB and *sqlx.Tx
+type SQLExecer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// ExecFenced execute a write statement guarded by the fencing token, it returns ErrStaleToken
+// if no row is affected. The statement must store the token and compare it with the stored one, e.g.
+//
+//	UPDATE orders SET status = ?, fence_token = ? WHERE id = ? AND fence_token <= ?
+//
+// With MySQL add clientFoundRows=true to the DSN, otherwise rewriting identical values with
+// the same token affects no row and is reported as stale.
+func ExecFenced(ctx context.Context, db SQLExecer, query string, args ...any) (sql.Result, error) {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return result, ErrStaleToken
+	}
+	return result, nil
+}
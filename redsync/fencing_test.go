@@ -0,0 +1,131 @@
+package redsync
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMutex_FencingToken(t *testing.T) {
+	_, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	var last int64
+	for i := 0; i < 3; i++ {
+		m := rs.NewMutex(ctx, "lock", SetFencing(true))
+		if err := m.Lock(); err != nil {
+			t.Fatal(err)
+		}
+		token := m.FencingToken()
+		if token <= last {
+			t.Fatalf("FencingToken() = %d, not greater than %d", token, last)
+		}
+		last = token
+		if err := m.Unlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if token := rs.NewMutex(ctx, "lock").FencingToken(); token != 0 {
+		t.Errorf("FencingToken() without fencing = %d", token)
+	}
+}
+
+func TestMutex_FencingTokenQuorum(t *testing.T) {
+	var (
+		nodes []*miniredis.Miniredis
+		pools []Pool
+	)
+	for i := 0; i < 3; i++ {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+		t.Cleanup(func() {
+			_ = client.Close()
+		})
+		nodes = append(nodes, mr)
+		pools = append(pools, NewPool(client))
+	}
+	rs := New(pools)
+	ctx := context.Background()
+
+	// 上一个持有者只在部分节点上计数
+	if err := nodes[0].Set("lock"+FenceKeySuffix, "10"); err != nil {
+		t.Fatal(err)
+	}
+
+	m := rs.NewMutex(ctx, "lock", SetFencing(true))
+	if err := m.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if token := m.FencingToken(); token != 11 {
+		t.Fatalf("FencingToken() = %d, want 11", token)
+	}
+	for i, mr := range nodes {
+		if v, _ := mr.Get("lock" + FenceKeySuffix); v != "11" {
+			t.Errorf("node %d counter = %q, want bumped to 11", i, v)
+		}
+	}
+}
+
+func TestFencedSet(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+
+	if _, _, err := FencedGet(ctx, client, "key"); !errors.Is(err, redis.Nil) {
+		t.Fatalf("FencedGet() on absent key = %v", err)
+	}
+	if err := FencedSet(ctx, client, "key", "v2", 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := FencedSet(ctx, client, "key", "v1", 1, 0); !errors.Is(err, ErrStaleToken) {
+		t.Fatalf("FencedSet() with stale token = %v", err)
+	}
+	if err := FencedSet(ctx, client, "key", "v2'", 2, 0); err != nil {
+		t.Fatalf("FencedSet() with the same token = %v", err)
+	}
+
+	value, token, err := FencedGet(ctx, client, "key")
+	if err != nil || value != "v2'" || token != 2 {
+		t.Errorf("FencedGet() = %q, %d, %v", value, token, err)
+	}
+}
+
+type fakeResult int64
+
+func (r fakeResult) LastInsertId() (int64, error) { return 0, nil }
+func (r fakeResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+type fakeExecer struct {
+	affected int64
+	query    string
+	args     []any
+}
+
+func (e *fakeExecer) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	e.query, e.args = query, args
+	return fakeResult(e.affected), nil
+}
+
+func TestExecFenced(t *testing.T) {
+	ctx := context.Background()
+	query := "UPDATE orders SET status = ?, fence_token = ? WHERE id = ? AND fence_token <= ?"
+
+	db := &fakeExecer{affected: 1}
+	if _, err := ExecFenced(ctx, db, query, "paid", 3, 1, 3); err != nil {
+		t.Fatalf("ExecFenced() = %v", err)
+	}
+	if db.query != query || len(db.args) != 4 {
+		t.Errorf("executed %q %v", db.query, db.args)
+	}
+
+	db.affected = 0
+	if _, err := ExecFenced(ctx, db, query, "paid", 2, 1, 2); !errors.Is(err, ErrStaleToken) {
+		t.Errorf("ExecFenced() on stale token = %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package redsync
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// lockOps implements the lock primitives on a redis node for the locks other than the plain mutex
+type lockOps interface {
+	acquire(ctx context.Context, client redis.Cmdable, name, token string, expiry time.Duration) (bool, error)
+	release(ctx context.Context, client redis.Cmdable, name, token string) (bool, error)
+	touch(ctx context.Context, client redis.Cmdable, name, token string, expiry time.Duration) (bool, error)
+}
+
+// hashLockPrelude is prepended to the acquire scripts of hash locks.
+// A hash lock stores every holder as a field of one hash, so that it lives in one cluster slot:
+// the field is the kind prefix and the token, the value is the expire time in milliseconds of the server clock.
+// The prelude drops the expired holders and collects the live ones into the table live.
+const hashLockPrelude = `
+	local t = redis.call("TIME")
+	local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+	local fields = redis.call("HGETALL", KEYS[1])
+	local live = {}
+	for i = 1, #fields, 2 do
+		if tonumber(fields[i + 1]) <= now then
+			redis.call("HDEL", KEYS[1], fields[i])
+		else
+			live[fields[i]] = true
+		end
+	end
+	local function hold(field, ttl)
+		redis.call("HSET", KEYS[1], field, now + tonumber(ttl))
+		if redis.call("PTTL", KEYS[1]) < tonumber(ttl) then
+			redis.call("PEXPIRE", KEYS[1], ttl)
+		end
+	end
+`
+
+var hashReleaseScript = redis.NewScript(`
+	if redis.call("HDEL", KEYS[1], ARGV[1]) == 0 then
+		return 0
+	end
+	if redis.call("HLEN", KEYS[1]) == 0 then
+		redis.call("DEL", KEYS[1])
+	end
+	return 1
+`)
+
+var hashTouchScript = redis.NewScript(`
+	local t = redis.call("TIME")
+	local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+	local expire = tonumber(redis.call("HGET", KEYS[1], ARGV[1]))
+	if expire == nil or expire <= now then
+		return 0
+	end
+	redis.call("HSET", KEYS[1], ARGV[1], now + tonumber(ARGV[2]))
+	if redis.call("PTTL", KEYS[1]) < tonumber(ARGV[2]) then
+		redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	end
+	return 1
+`)
+
+// hashOps implements lockOps on a hash lock, the acquire script is called with
+// the field, the expiry in milliseconds and args
+type hashOps struct {
+	prefix        string
+	acquireScript *redis.Script
+	args          []any
+}
+
+func (o *hashOps) acquire(ctx context.Context, client redis.Cmdable, name, token string, expiry time.Duration) (bool, error) {
+	args := append([]any{o.prefix + token, int(expiry / time.Millisecond)}, o.args...)
+	result, err := o.acquireScript.Run(ctx, client, []string{name}, args...).Int()
+	return result == 1, err
+}
+
+func (o *hashOps) release(ctx context.Context, client redis.Cmdable, name, token string) (bool, error) {
+	return releaseField(ctx, client, name, o.prefix+token)
+}
+
+func (o *hashOps) touch(ctx context.Context, client redis.Cmdable, name, token string, expiry time.Duration) (bool, error) {
+	result, err := hashTouchScript.Run(ctx, client, []string{name}, o.prefix+token, int(expiry/time.Millisecond)).Int()
+	return result == 1, err
+}
+
+func releaseField(ctx context.Context, client redis.Cmdable, name, field string) (bool, error) {
+	result, err := hashReleaseScript.Run(ctx, client, []string{name}, field).Int()
+	return result == 1, err
+}
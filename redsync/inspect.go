@@ -0,0 +1,118 @@
+package redsync
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var holderScript = redis.NewScript(`
+	local kind = redis.call("TYPE", KEYS[1])["ok"]
+	if kind == "string" then
+		return redis.call("GET", KEYS[1])
+	end
+	if kind ~= "hash" then
+		return ""
+	end
+
+	local owner = redis.call("HGET", KEYS[1], "owner")
+	if owner then
+		return owner
+	end
+
+	local t = redis.call("TIME")
+	local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+	local fields = redis.call("HGETALL", KEYS[1])
+	local holders = {}
+	for i = 1, #fields, 2 do
+		if tonumber(fields[i + 1]) > now then
+			table.insert(holders, fields[i])
+		end
+	end
+	table.sort(holders)
+	return table.concat(holders, ",")
+`)
+
+// Holder return the token holding the lock name on a quorum of the nodes, empty if it is not held.
+// The holders of a RWMutex or Semaphore are joined by ",", each prefixed by its kind:
+// "r:" reader, "w:" writer, "p:" waiting writer and "s:" semaphore.
+func (r *Redsync) Holder(ctx context.Context, name string) (string, error) {
+	var (
+		votes    = make(map[string]int)
+		answered int
+		firstErr error
+	)
+	for _, pool := range r.pools {
+		holder, err := holderScript.Run(ctx, pool.Get(), []string{name}).Text()
+		if err != nil {
+			firstErr = cmp.Or(firstErr, err)
+			continue
+		}
+		answered++
+		votes[holder]++
+	}
+	if answered < r.quorum() {
+		return "", fmt.Errorf("%w: %v", ErrUnreachable, firstErr)
+	}
+
+	for holder, n := range votes {
+		if n >= r.quorum() {
+			return holder, nil
+		}
+	}
+	return "", nil
+}
+
+// TTL return the time the lock name stays held on a quorum of the nodes, 0 if it is not held
+func (r *Redsync) TTL(ctx context.Context, name string) (time.Duration, error) {
+	var (
+		ttls     []time.Duration
+		firstErr error
+	)
+	for _, pool := range r.pools {
+		ttl, err := pool.Get().PTTL(ctx, name).Result()
+		if err != nil {
+			firstErr = cmp.Or(firstErr, err)
+			continue
+		}
+		ttls = append(ttls, max(ttl, 0))
+	}
+	if len(ttls) < r.quorum() {
+		return 0, fmt.Errorf("%w: %v", ErrUnreachable, firstErr)
+	}
+
+	// 按剩余时间降序，第 quorum 个即多数派仍持有锁的时长
+	slices.SortFunc(ttls, func(a, b time.Duration) int {
+		return cmp.Compare(b, a)
+	})
+	return ttls[r.quorum()-1], nil
+}
+
+// ForceUnlock delete the lock name on all the nodes regardless of the holder, it is for admins
+// to recover stuck locks. The holders are not notified, they find the lock lost on Extend or Unlock.
+// The fencing counter is kept so that fencing tokens stay monotonic.
+func (r *Redsync) ForceUnlock(ctx context.Context, name string) error {
+	var (
+		deleted  int
+		firstErr error
+	)
+	for _, pool := range r.pools {
+		if err := pool.Get().Del(ctx, name).Err(); err != nil {
+			firstErr = cmp.Or(firstErr, err)
+			continue
+		}
+		deleted++
+	}
+	if deleted < r.quorum() {
+		return fmt.Errorf("%w: %v", ErrUnreachable, firstErr)
+	}
+	return nil
+}
+
+func (r *Redsync) quorum() int {
+	return len(r.pools)/2 + 1
+}
@@ -0,0 +1,79 @@
+package redsync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRedsync_Inspect(t *testing.T) {
+	mr, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	if holder, err := rs.Holder(ctx, "lock"); err != nil || holder != "" {
+		t.Fatalf("Holder() of a free lock = %q, %v", holder, err)
+	}
+	if ttl, err := rs.TTL(ctx, "lock"); err != nil || ttl != 0 {
+		t.Fatalf("TTL() of a free lock = %v, %v", ttl, err)
+	}
+
+	m := rs.NewMutex(ctx, "lock", SetExpiry(time.Minute))
+	if err := m.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if holder, err := rs.Holder(ctx, "lock"); err != nil || holder != m.GetToken() {
+		t.Errorf("Holder() = %q, %v, want %q", holder, err, m.GetToken())
+	}
+	if ttl, err := rs.TTL(ctx, "lock"); err != nil || ttl <= 0 || ttl > time.Minute {
+		t.Errorf("TTL() = %v, %v", ttl, err)
+	}
+
+	if err := rs.ForceUnlock(ctx, "lock"); err != nil {
+		t.Fatalf("ForceUnlock() = %v", err)
+	}
+	if mr.Exists("lock") {
+		t.Fatal("lock exists after ForceUnlock")
+	}
+	if err := m.Unlock(); !errors.Is(err, ErrLockLost) {
+		t.Errorf("Unlock() after ForceUnlock = %v, want ErrLockLost", err)
+	}
+}
+
+func TestRedsync_HolderOfHashLocks(t *testing.T) {
+	_, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	m := rs.NewMutex(ctx, "reentrant", SetReentrant(true), SetToken("owner"))
+	if err := m.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if holder, err := rs.Holder(ctx, "reentrant"); err != nil || holder != "owner" {
+		t.Errorf("Holder() of reentrant mutex = %q, %v", holder, err)
+	}
+
+	for _, token := range []string{"b", "a"} {
+		if err := rs.NewRWMutex(ctx, "rw", SetToken(token)).RLock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if holder, err := rs.Holder(ctx, "rw"); err != nil || holder != "r:a,r:b" {
+		t.Errorf("Holder() of RWMutex = %q, %v", holder, err)
+	}
+}
+
+func TestRedsync_InspectUnreachable(t *testing.T) {
+	mr, rs := newTestRedsync(t)
+	ctx := context.Background()
+	mr.Close()
+
+	if _, err := rs.Holder(ctx, "lock"); !errors.Is(err, ErrUnreachable) {
+		t.Errorf("Holder() = %v, want ErrUnreachable", err)
+	}
+	if _, err := rs.TTL(ctx, "lock"); !errors.Is(err, ErrUnreachable) {
+		t.Errorf("TTL() = %v, want ErrUnreachable", err)
+	}
+	if err := rs.ForceUnlock(ctx, "lock"); !errors.Is(err, ErrUnreachable) {
+		t.Errorf("ForceUnlock() = %v, want ErrUnreachable", err)
+	}
+}
@@ -1,12 +1,14 @@
 package redsync
 
 import (
+	"cmp"
 	"context"
 	"crypto/rand"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -33,6 +35,16 @@ type Mutex struct {
 	nodeMutex sync.Mutex
 
 	pools []Pool
+
+	watchdog bool
+	watch    atomic.Pointer[watcher]
+
+	fencing bool
+	fence   int64
+
+	ops       lockOps // nil for the plain mutex
+	reentrant bool
+	tokenCtx  atomic.Pointer[context.Context] // the context of the mutex carrying the token once locked in the reentrant mode
 }
 
 // GetToken return the token id for the mutex
@@ -40,80 +52,232 @@ func (m *Mutex) GetToken() string {
 	return m.token
 }
 
-// Lock locks m. In case it returns an error on failure, you may retry to acquire the lock by calling this method again.
+// Lock locks m with the context of the mutex, see LockContext.
 func (m *Mutex) Lock() error {
+	return m.LockContext(m.ctx)
+}
+
+// LockContext locks m, retrying up to the configured tries with a random delay in between.
+// It returns ctx.Err() as soon as ctx is done, ErrFailed if the lock is held by others,
+// or ErrUnreachable if redis errors prevented the quorum. In case it returns an error on failure,
+// you may retry to acquire the lock by calling this method again.
+func (m *Mutex) LockContext(ctx context.Context) error {
 	m.nodeMutex.Lock()
 	defer m.nodeMutex.Unlock()
 
-	if m.token == "" {
-		token, err := m.genToken()
-		if err != nil {
-			return err
-		}
-		m.token = token
+	if err := m.ensureToken(ctx); err != nil {
+		return err
 	}
 
+	err := ErrFailed
 	for i := 0; i < m.tries; i++ {
-		start := time.Now()
-
-		n := 0
-		for _, pool := range m.pools {
-			ok := m.acquire(pool, m.token)
-			if ok {
-				n++
+		if i > 0 {
+			timer := time.NewTimer(m.getDelay())
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
 			}
 		}
-
-		until := time.Now().Add(m.expiry - time.Now().Sub(start) - time.Duration(int64(float64(m.expiry)*m.factor)) + 2*time.Millisecond)
-		if n >= m.quorum && time.Now().Before(until) {
-			m.until = until
-			return nil
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
-		for _, pool := range m.pools {
-			m.release(pool, m.token)
+		if err = m.tryAcquire(ctx); err == nil {
+			return nil
 		}
-
-		time.Sleep(m.getDelay())
 	}
+	return err
+}
+
+// TryLock makes a single attempt to lock m without waiting.
+// It returns ErrFailed if the lock is held by others, or ErrUnreachable if redis errors prevented the quorum.
+func (m *Mutex) TryLock(ctx context.Context) error {
+	m.nodeMutex.Lock()
+	defer m.nodeMutex.Unlock()
 
-	return ErrFailed
+	if err := m.ensureToken(ctx); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return m.tryAcquire(ctx)
 }
 
-// Unlock unlocks m and returns the status of unlock. It is a run-time error if m is not locked on entry to Unlock.
-func (m *Mutex) Unlock() bool {
+// Unlock unlocks m. It returns ErrLockLost if the lock is no longer held,
+// or ErrUnreachable if redis errors prevented the quorum.
+// It is not affected by the cancellation of the context of the mutex, see detached.
+func (m *Mutex) Unlock() error {
+	m.stopWatchdog()
+
 	m.nodeMutex.Lock()
 	defer m.nodeMutex.Unlock()
 
-	n := 0
+	ctx, cancel := m.detached()
+	defer cancel()
+
+	var (
+		held, lost int
+		firstErr   error
+	)
 	for _, pool := range m.pools {
-		ok := m.release(pool, m.token)
-		if ok {
-			n++
+		ok, err := m.release(ctx, pool, m.token)
+		switch {
+		case err != nil:
+			firstErr = cmp.Or(firstErr, err)
+		case ok:
+			held++
+		default:
+			lost++
 		}
 	}
-	return n >= m.quorum
+	if held >= m.quorum {
+		return nil
+	}
+	return m.quorumError(lost, ErrLockLost, firstErr)
 }
 
-// Extend resets the mutex's expiry and returns the status of expiry extension. It is a run-time error if m is not locked on entry to Extend.
-func (m *Mutex) Extend() bool {
+// Extend resets the mutex's expiry. It returns ErrLockLost if the lock is no longer held,
+// or ErrUnreachable if redis errors prevented the quorum, the lock is released on failure.
+// Like Unlock, it is not affected by the cancellation of the context of the mutex.
+func (m *Mutex) Extend() error {
 	m.nodeMutex.Lock()
 	defer m.nodeMutex.Unlock()
 
-	n := 0
+	ctx, cancel := m.detached()
+	defer cancel()
+
+	if err := m.extend(ctx); err != nil {
+		m.releaseAll(ctx)
+		return err
+	}
+	return nil
+}
+
+// detached return the context of the mutex without its cancellation and bounded by the expiry,
+// so that a canceled request does not leave the lock behind until it expires
+func (m *Mutex) detached() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(m.ctx), m.expiry)
+}
+
+// extend resets the expiry on all the nodes and the validity of the lock on success
+func (m *Mutex) extend(ctx context.Context) error {
+	start := time.Now()
+
+	var (
+		held, lost int
+		firstErr   error
+	)
 	for _, pool := range m.pools {
-		ok := m.touch(pool, m.token, m.expiry)
-		if ok {
-			n++
+		ok, err := m.touch(ctx, pool, m.token, m.expiry)
+		switch {
+		case err != nil:
+			firstErr = cmp.Or(firstErr, err)
+		case ok:
+			held++
+		default:
+			lost++
 		}
 	}
-	if n >= m.quorum {
-		return true
+	if held >= m.quorum {
+		m.until = m.validUntil(start)
+		return nil
 	}
+	return m.quorumError(lost, ErrLockLost, firstErr)
+}
 
+func (m *Mutex) ensureToken(ctx context.Context) error {
+	if m.token != "" {
+		return nil
+	}
+	if m.reentrant {
+		if token := TokenFromContext(ctx); token != "" {
+			m.token = token
+			return nil
+		}
+	}
+	token, err := m.genToken()
+	if err != nil {
+		return err
+	}
+	m.token = token
+	return nil
+}
+
+// tryAcquire makes one attempt on all the nodes, the partially acquired nodes are released on failure
+func (m *Mutex) tryAcquire(ctx context.Context) error {
+	start := time.Now()
+
+	var (
+		held, taken int
+		firstErr    error
+		fence       int64
+	)
 	for _, pool := range m.pools {
-		m.release(pool, m.token)
+		var (
+			ok  bool
+			err error
+		)
+		if m.fencing {
+			var n int64
+			n, err = m.acquireFenced(ctx, pool, m.token)
+			ok, fence = n > 0, max(fence, n)
+		} else {
+			ok, err = m.acquire(ctx, pool, m.token)
+		}
+		switch {
+		case err != nil:
+			firstErr = cmp.Or(firstErr, err)
+		case ok:
+			held++
+		default:
+			taken++
+		}
 	}
-	return false
+
+	until := m.validUntil(start)
+	if held >= m.quorum && time.Now().Before(until) {
+		m.until = until
+		if m.fencing {
+			m.fence = fence
+			m.bumpFence(ctx, fence)
+		}
+		if m.reentrant {
+			// 嵌套路径用 Context() 加锁即可重入，不必手动传递 token
+			ctx = ContextWithToken(ctx, m.token)
+			tokenCtx := ContextWithToken(m.ctx, m.token)
+			m.tokenCtx.Store(&tokenCtx)
+		}
+		m.startWatchdog(ctx)
+		return nil
+	}
+
+	m.releaseAll(ctx)
+	return m.quorumError(taken, ErrFailed, firstErr)
+}
+
+// validUntil return the time the lock set at start is valid until, with the clock drift deducted
+func (m *Mutex) validUntil(start time.Time) time.Time {
+	return time.Now().Add(m.expiry - time.Now().Sub(start) - time.Duration(int64(float64(m.expiry)*m.factor)) + 2*time.Millisecond)
+}
+
+// releaseAll release the nodes even if ctx is canceled, so that a failed attempt does not leave the lock behind
+func (m *Mutex) releaseAll(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), m.expiry)
+	defer cancel()
+	for _, pool := range m.pools {
+		_, _ = m.release(ctx, pool, m.token)
+	}
+}
+
+// quorumError classify the failure to reach the quorum: refused is returned if enough nodes
+// answered negatively to make the quorum impossible, otherwise ErrUnreachable with the first redis error
+func (m *Mutex) quorumError(negative int, refused error, firstErr error) error {
+	if firstErr == nil || negative > len(m.pools)-m.quorum {
+		return refused
+	}
+	return fmt.Errorf("%w: %v", ErrUnreachable, firstErr)
 }
 
 func (m *Mutex) genToken() (string, error) {
@@ -126,16 +290,22 @@ func (m *Mutex) genToken() (string, error) {
 }
 
 func (m *Mutex) getDelay() time.Duration {
-	var n int64
+	if m.delayMax <= m.delayMin {
+		return max(m.delayMin, 0)
+	}
+
+	var n uint64
 	_ = binary.Read(rand.Reader, binary.LittleEndian, &n)
-	n %= int64(m.delayMax - m.delayMin)
+	n %= uint64(m.delayMax - m.delayMin)
 	return time.Duration(n) + m.delayMin
 }
 
-func (m *Mutex) acquire(pool Pool, token string) bool {
+func (m *Mutex) acquire(ctx context.Context, pool Pool, token string) (bool, error) {
 	client := pool.Get()
-	ok, err := client.SetNX(m.ctx, m.name, token, m.expiry).Result()
-	return err == nil && ok
+	if m.ops != nil {
+		return m.ops.acquire(ctx, client, m.name, token, m.expiry)
+	}
+	return client.SetNX(ctx, m.name, token, m.expiry).Result()
 }
 
 var deleteScript = redis.NewScript(`
@@ -146,27 +316,29 @@ var deleteScript = redis.NewScript(`
 	end
 `)
 
-func (m *Mutex) release(pool Pool, token string) bool {
+func (m *Mutex) release(ctx context.Context, pool Pool, token string) (bool, error) {
 	client := pool.Get()
-	result, err := deleteScript.Run(m.ctx, client, []string{m.name}, token).Int()
-	return err == nil && result == 1
+	if m.ops != nil {
+		return m.ops.release(ctx, client, m.name, token)
+	}
+	result, err := deleteScript.Run(ctx, client, []string{m.name}, token).Int()
+	return result == 1, err
 }
 
+// touchScript only extends the key still held with the token, an expired key is not taken again
+// since another holder may have taken and released it in between
 var touchScript = redis.NewScript(`
 	if redis.call("GET", KEYS[1]) == ARGV[1] then
 		return redis.call("PEXPIRE", KEYS[1], ARGV[2])
-	else
-		local rv = redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2])
-		if type(rv) == "table" then
-			return 1
-		else
-			return 0
-		end
 	end
+	return 0
 `)
 
-func (m *Mutex) touch(pool Pool, token string, expiry time.Duration) bool {
+func (m *Mutex) touch(ctx context.Context, pool Pool, token string, expiry time.Duration) (bool, error) {
 	client := pool.Get()
-	result, err := touchScript.Run(m.ctx, client, []string{m.name}, token, int(expiry/time.Millisecond)).Int()
-	return err == nil && result == 1
+	if m.ops != nil {
+		return m.ops.touch(ctx, client, m.name, token, expiry)
+	}
+	result, err := touchScript.Run(ctx, client, []string{m.name}, token, int(expiry/time.Millisecond)).Int()
+	return result == 1, err
 }
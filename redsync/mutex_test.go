@@ -0,0 +1,191 @@
+package redsync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedsync(t *testing.T) (*miniredis.Miniredis, *Redsync) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return mr, New([]Pool{NewPool(client)})
+}
+
+func TestMutex_TryLock(t *testing.T) {
+	_, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	m1 := rs.NewMutex(ctx, "lock")
+	if err := m1.TryLock(ctx); err != nil {
+		t.Fatalf("TryLock() = %v", err)
+	}
+
+	m2 := rs.NewMutex(ctx, "lock")
+	if err := m2.TryLock(ctx); !errors.Is(err, ErrFailed) {
+		t.Fatalf("TryLock() on held lock = %v, want ErrFailed", err)
+	}
+
+	if err := m1.Unlock(); err != nil {
+		t.Fatalf("Unlock() = %v", err)
+	}
+	if err := m2.TryLock(ctx); err != nil {
+		t.Fatalf("TryLock() after unlock = %v", err)
+	}
+}
+
+func TestMutex_LockContextCanceled(t *testing.T) {
+	_, rs := newTestRedsync(t)
+
+	holder := rs.NewMutex(context.Background(), "lock")
+	if err := holder.Lock(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	m := rs.NewMutex(ctx, "lock", SetTries(1000), SetRetryDelay(10*time.Millisecond, 20*time.Millisecond))
+	start := time.Now()
+	err := m.LockContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("LockContext() = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("LockContext() returned after %v", elapsed)
+	}
+}
+
+func TestMutex_LockContextRetries(t *testing.T) {
+	_, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	holder := rs.NewMutex(ctx, "lock")
+	if err := holder.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(30*time.Millisecond, func() {
+		_ = holder.Unlock()
+	})
+
+	m := rs.NewMutex(ctx, "lock", SetRetryDelay(5*time.Millisecond, 5*time.Millisecond))
+	if err := m.LockContext(ctx); err != nil {
+		t.Fatalf("LockContext() = %v", err)
+	}
+}
+
+func TestMutex_UnlockAfterCancel(t *testing.T) {
+	mr, rs := newTestRedsync(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := rs.NewMutex(ctx, "lock")
+	if err := m.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	if err := m.Extend(); err != nil {
+		t.Errorf("Extend() after cancel = %v", err)
+	}
+	if err := m.Unlock(); err != nil {
+		t.Fatalf("Unlock() after cancel = %v", err)
+	}
+	if mr.Exists("lock") {
+		t.Error("lock is left behind after unlock")
+	}
+}
+
+func TestMutex_LockLost(t *testing.T) {
+	mr, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	m := rs.NewMutex(ctx, "lock")
+	if err := m.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Extend(); err != nil {
+		t.Fatalf("Extend() = %v", err)
+	}
+
+	if err := mr.Set("lock", "other"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Extend(); !errors.Is(err, ErrLockLost) {
+		t.Errorf("Extend() on lost lock = %v, want ErrLockLost", err)
+	}
+	if err := m.Unlock(); !errors.Is(err, ErrLockLost) {
+		t.Errorf("Unlock() on lost lock = %v, want ErrLockLost", err)
+	}
+	if v, _ := mr.Get("lock"); v != "other" {
+		t.Errorf("lock value = %q, the other holder must be kept", v)
+	}
+}
+
+func TestMutex_ExtendAfterExpiry(t *testing.T) {
+	mr, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	m := rs.NewMutex(ctx, "lock")
+	if err := m.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	mr.Del("lock")
+	if err := m.Extend(); !errors.Is(err, ErrLockLost) {
+		t.Errorf("Extend() after the key is deleted = %v, want ErrLockLost", err)
+	}
+	if mr.Exists("lock") {
+		t.Error("Extend() takes the expired lock again")
+	}
+
+	if err := m.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.ForceUnlock(ctx, "lock"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Extend(); !errors.Is(err, ErrLockLost) {
+		t.Errorf("Extend() after ForceUnlock = %v, want ErrLockLost", err)
+	}
+}
+
+func TestMutex_Unreachable(t *testing.T) {
+	mr, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	m := rs.NewMutex(ctx, "lock")
+	if err := m.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	mr.Close()
+
+	if err := m.Unlock(); !errors.Is(err, ErrUnreachable) {
+		t.Errorf("Unlock() = %v, want ErrUnreachable", err)
+	}
+	if err := m.Extend(); !errors.Is(err, ErrUnreachable) {
+		t.Errorf("Extend() = %v, want ErrUnreachable", err)
+	}
+	if err := rs.NewMutex(ctx, "lock").TryLock(ctx); !errors.Is(err, ErrUnreachable) {
+		t.Errorf("TryLock() = %v, want ErrUnreachable", err)
+	}
+}
+
+func TestMutex_GetDelay(t *testing.T) {
+	m := &Mutex{delayMin: 10 * time.Millisecond, delayMax: 10 * time.Millisecond}
+	if d := m.getDelay(); d != 10*time.Millisecond {
+		t.Errorf("getDelay() = %v", d)
+	}
+
+	m.delayMax = 20 * time.Millisecond
+	for i := 0; i < 100; i++ {
+		if d := m.getDelay(); d < m.delayMin || d >= m.delayMax {
+			t.Fatalf("getDelay() = %v, out of [%v, %v)", d, m.delayMin, m.delayMax)
+		}
+	}
+}
@@ -15,3 +15,16 @@ type defaultPool struct{}
 func (p *defaultPool) Get() redis.Cmdable {
 	return rdb.Get()
 }
+
+type clientPool struct {
+	client redis.Cmdable
+}
+
+// NewPool create a pool always returning the given client
+func NewPool(client redis.Cmdable) Pool {
+	return &clientPool{client: client}
+}
+
+func (p *clientPool) Get() redis.Cmdable {
+	return p.client
+}
@@ -31,12 +31,24 @@ func (r *Redsync) NewMutex(ctx context.Context, name string, options ...Option)
 		delayMin: 50 * time.Millisecond,
 		delayMax: 500 * time.Millisecond,
 		factor:   0.01,
-		quorum:   len(r.pools)/2 + 1,
+		quorum:   r.quorum(),
 		pools:    r.pools,
 	}
 	for _, o := range options {
 		o.Apply(m)
 	}
+	if m.reentrant {
+		m.ops = reentrantOps{}
+		m.fencing = false
+	}
+	return m
+}
+
+// newMutex create a mutex implemented by ops, fencing is only supported by the plain mutex
+func (r *Redsync) newMutex(ctx context.Context, name string, ops lockOps, options []Option) *Mutex {
+	m := r.NewMutex(ctx, name, options...)
+	m.ops = ops
+	m.fencing = false
 	return m
 }
 
@@ -0,0 +1,86 @@
+package redsync
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type tokenCtxKey struct{}
+
+// ContextWithToken return a context carrying the lock token, reentrant mutexes locked with it
+// reuse the token, so that nested code paths taking the same lock reenter it instead of deadlocking, e.g.
+//
+//	ctx = redsync.ContextWithToken(ctx, m.GetToken())
+func ContextWithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenCtxKey{}, token)
+}
+
+// TokenFromContext return the lock token carried by ctx, empty if absent
+func TokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(tokenCtxKey{}).(string)
+	return token
+}
+
+// SetReentrant enable the reentrant mode: the lock is a redis hash of the owner token and the hold count,
+// locking it again with the same token increments the count and every Unlock decrements it.
+// Unless SetToken is used the token is taken from the locking context, see ContextWithToken.
+//
+// The token must be propagated to the nested code paths: pass down Mutex.Context(), which carries
+// the token once locked, and create and lock the nested mutexes with it. A nested mutex locked with
+// a context without the token, e.g. the original request context, is another holder and waits
+// for the outer lock until it gives up.
+//
+// All the mutexes of a name must agree on the mode, and SetFencing is not supported.
+func SetReentrant(enabled bool) Option {
+	return OptionFunc(func(m *Mutex) {
+		m.reentrant = enabled
+	})
+}
+
+var reentrantAcquireScript = redis.NewScript(`
+	local owner = redis.call("HGET", KEYS[1], "owner")
+	if owner and owner ~= ARGV[1] then
+		return 0
+	end
+	redis.call("HSET", KEYS[1], "owner", ARGV[1])
+	redis.call("HINCRBY", KEYS[1], "count", 1)
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+`)
+
+var reentrantReleaseScript = redis.NewScript(`
+	if redis.call("HGET", KEYS[1], "owner") ~= ARGV[1] then
+		return 0
+	end
+	if redis.call("HINCRBY", KEYS[1], "count", -1) <= 0 then
+		redis.call("DEL", KEYS[1])
+	end
+	return 1
+`)
+
+var reentrantTouchScript = redis.NewScript(`
+	if redis.call("HGET", KEYS[1], "owner") ~= ARGV[1] then
+		return 0
+	end
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+`)
+
+// reentrantOps implements lockOps on the hash of the owner and the hold count
+type reentrantOps struct{}
+
+func (reentrantOps) acquire(ctx context.Context, client redis.Cmdable, name, token string, expiry time.Duration) (bool, error) {
+	result, err := reentrantAcquireScript.Run(ctx, client, []string{name}, token, int(expiry/time.Millisecond)).Int()
+	return result == 1, err
+}
+
+func (reentrantOps) release(ctx context.Context, client redis.Cmdable, name, token string) (bool, error) {
+	result, err := reentrantReleaseScript.Run(ctx, client, []string{name}, token).Int()
+	return result == 1, err
+}
+
+func (reentrantOps) touch(ctx context.Context, client redis.Cmdable, name, token string, expiry time.Duration) (bool, error) {
+	result, err := reentrantTouchScript.Run(ctx, client, []string{name}, token, int(expiry/time.Millisecond)).Int()
+	return result == 1, err
+}
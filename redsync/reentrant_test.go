@@ -0,0 +1,113 @@
+package redsync
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMutex_Reentrant(t *testing.T) {
+	mr, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	outer := rs.NewMutex(ctx, "lock", SetReentrant(true))
+	if err := outer.Lock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 嵌套路径通过 context 继承 token 重入
+	nestedCtx := ContextWithToken(ctx, outer.GetToken())
+	inner := rs.NewMutex(nestedCtx, "lock", SetReentrant(true))
+	if err := inner.TryLock(nestedCtx); err != nil {
+		t.Fatalf("TryLock() of the nested path = %v", err)
+	}
+	if inner.GetToken() != outer.GetToken() {
+		t.Fatalf("nested token = %q, want %q", inner.GetToken(), outer.GetToken())
+	}
+	if count := mr.HGet("lock", "count"); count != "2" {
+		t.Fatalf("hold count = %q, want 2", count)
+	}
+
+	other := rs.NewMutex(ctx, "lock", SetReentrant(true))
+	if err := other.TryLock(ctx); !errors.Is(err, ErrFailed) {
+		t.Fatalf("TryLock() of another holder = %v, want ErrFailed", err)
+	}
+
+	if err := inner.Extend(); err != nil {
+		t.Fatalf("Extend() = %v", err)
+	}
+	if err := inner.Unlock(); err != nil {
+		t.Fatalf("Unlock() of the nested path = %v", err)
+	}
+	if err := other.TryLock(ctx); !errors.Is(err, ErrFailed) {
+		t.Fatalf("TryLock() while the outer holds = %v, want ErrFailed", err)
+	}
+
+	if err := outer.Unlock(); err != nil {
+		t.Fatalf("Unlock() = %v", err)
+	}
+	if mr.Exists("lock") {
+		t.Fatal("lock is held after the last Unlock")
+	}
+	if err := outer.Unlock(); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("extra Unlock() = %v, want ErrLockLost", err)
+	}
+	if err := other.TryLock(ctx); err != nil {
+		t.Fatalf("TryLock() after release = %v", err)
+	}
+}
+
+func TestMutex_NotReentrant(t *testing.T) {
+	_, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	outer := rs.NewMutex(ctx, "lock")
+	if err := outer.Lock(); err != nil {
+		t.Fatal(err)
+	}
+
+	nestedCtx := ContextWithToken(ctx, outer.GetToken())
+	if err := rs.NewMutex(nestedCtx, "lock").TryLock(nestedCtx); !errors.Is(err, ErrFailed) {
+		t.Fatalf("TryLock() of a plain mutex = %v, want ErrFailed", err)
+	}
+}
+
+func TestMutex_ReentrantNested(t *testing.T) {
+	mr, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	nested := func(ctx context.Context) error {
+		m := rs.NewMutex(ctx, "lock", SetReentrant(true), SetTries(1))
+		if err := m.LockContext(ctx); err != nil {
+			return err
+		}
+		return m.Unlock()
+	}
+
+	outer := rs.NewMutex(ctx, "lock", SetReentrant(true))
+	if err := outer.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := nested(outer.Context()); err != nil {
+		t.Fatalf("nested lock with Context() = %v", err)
+	}
+	if err := nested(ctx); !errors.Is(err, ErrFailed) {
+		t.Fatalf("nested lock without the token = %v, want ErrFailed", err)
+	}
+
+	watched := rs.NewMutex(ctx, "watched", SetReentrant(true), SetWatchdog(true))
+	if err := watched.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if token := TokenFromContext(watched.Context()); token != watched.GetToken() {
+		t.Errorf("token of the watchdog context = %q, want %q", token, watched.GetToken())
+	}
+	_ = watched.Unlock()
+
+	if err := outer.Unlock(); err != nil {
+		t.Fatalf("Unlock() = %v", err)
+	}
+	if mr.Exists("lock") {
+		t.Fatal("lock is held after the last Unlock")
+	}
+}
@@ -0,0 +1,123 @@
+package redsync
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// field prefixes of the holders of a RWMutex
+const (
+	readerPrefix  = "r:"
+	writerPrefix  = "w:"
+	pendingPrefix = "p:"
+)
+
+var readLockScript = redis.NewScript(hashLockPrelude + `
+	for field in pairs(live) do
+		local kind = string.sub(field, 1, 2)
+		if kind == "w:" or kind == "p:" then
+			return 0
+		end
+	end
+	hold(ARGV[1], ARGV[2])
+	return 1
+`)
+
+var writeLockScript = redis.NewScript(hashLockPrelude + `
+	local pending = "p:" .. string.sub(ARGV[1], 3)
+	for field in pairs(live) do
+		local kind = string.sub(field, 1, 2)
+		if kind == "r:" or (kind == "w:" and field ~= ARGV[1]) then
+			-- 登记等待中的写者，阻止新的读者进入
+			hold(pending, ARGV[2])
+			return 0
+		end
+	end
+	redis.call("HDEL", KEYS[1], pending)
+	hold(ARGV[1], ARGV[2])
+	return 1
+`)
+
+// RWMutex is a distributed writer-preferring reader/writer lock, the holder holds it in one mode at a time.
+// A writer waiting for the readers blocks new readers until it acquires the lock or gives up,
+// the waiting mark expires with the expiry if the writer disappears.
+// The embedded Mutex is the write lock, SetFencing is not supported.
+type RWMutex struct {
+	*Mutex
+	reader *Mutex
+}
+
+// NewRWMutex returns a new distributed reader/writer lock with given name.
+func (r *Redsync) NewRWMutex(ctx context.Context, name string, options ...Option) *RWMutex {
+	return &RWMutex{
+		Mutex:  r.newMutex(ctx, name, &hashOps{prefix: writerPrefix, acquireScript: writeLockScript}, options),
+		reader: r.newMutex(ctx, name, &hashOps{prefix: readerPrefix, acquireScript: readLockScript}, options),
+	}
+}
+
+// NewRWMutex create a reader/writer lock using default redsync
+func NewRWMutex(ctx context.Context, name string, options ...Option) *RWMutex {
+	return defaultRedsync.NewRWMutex(ctx, name, options...)
+}
+
+// Lock locks rw for writing with the context of the mutex, see LockContext.
+func (rw *RWMutex) Lock() error {
+	return rw.LockContext(rw.ctx)
+}
+
+// LockContext locks rw for writing, it has the semantics of Mutex.LockContext.
+func (rw *RWMutex) LockContext(ctx context.Context) error {
+	err := rw.Mutex.LockContext(ctx)
+	if err != nil {
+		rw.dropPending(ctx)
+	}
+	return err
+}
+
+// TryLock makes a single attempt to lock rw for writing, it has the semantics of Mutex.TryLock.
+func (rw *RWMutex) TryLock(ctx context.Context) error {
+	err := rw.Mutex.TryLock(ctx)
+	if err != nil {
+		rw.dropPending(ctx)
+	}
+	return err
+}
+
+// RLock locks rw for reading with the context of the mutex, see RLockContext.
+func (rw *RWMutex) RLock() error {
+	return rw.reader.Lock()
+}
+
+// RLockContext locks rw for reading, it has the semantics of Mutex.LockContext.
+func (rw *RWMutex) RLockContext(ctx context.Context) error {
+	return rw.reader.LockContext(ctx)
+}
+
+// TryRLock makes a single attempt to lock rw for reading, it has the semantics of Mutex.TryLock.
+func (rw *RWMutex) TryRLock(ctx context.Context) error {
+	return rw.reader.TryLock(ctx)
+}
+
+// RUnlock unlocks the read lock, it has the semantics of Mutex.Unlock.
+func (rw *RWMutex) RUnlock() error {
+	return rw.reader.Unlock()
+}
+
+// RExtend resets the read lock's expiry, it has the semantics of Mutex.Extend.
+func (rw *RWMutex) RExtend() error {
+	return rw.reader.Extend()
+}
+
+// RLocker return the read lock, e.g. for its Lost and Context when the watchdog is enabled.
+func (rw *RWMutex) RLocker() *Mutex {
+	return rw.reader
+}
+
+// dropPending remove the waiting mark of the writer which gave up, so that readers are not blocked
+func (rw *RWMutex) dropPending(ctx context.Context) {
+	ctx = context.WithoutCancel(ctx)
+	for _, pool := range rw.pools {
+		_, _ = releaseField(ctx, pool.Get(), rw.name, pendingPrefix+rw.GetToken())
+	}
+}
@@ -0,0 +1,132 @@
+package redsync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRWMutex_Readers(t *testing.T) {
+	_, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	r1 := rs.NewRWMutex(ctx, "rw")
+	r2 := rs.NewRWMutex(ctx, "rw")
+	if err := r1.TryRLock(ctx); err != nil {
+		t.Fatalf("TryRLock() = %v", err)
+	}
+	if err := r2.TryRLock(ctx); err != nil {
+		t.Fatalf("TryRLock() of the second reader = %v", err)
+	}
+
+	w := rs.NewRWMutex(ctx, "rw")
+	if err := w.TryLock(ctx); !errors.Is(err, ErrFailed) {
+		t.Fatalf("TryLock() with readers = %v, want ErrFailed", err)
+	}
+
+	for _, r := range []*RWMutex{r1, r2} {
+		if err := r.RUnlock(); err != nil {
+			t.Fatalf("RUnlock() = %v", err)
+		}
+	}
+	if err := w.TryLock(ctx); err != nil {
+		t.Fatalf("TryLock() after readers left = %v", err)
+	}
+	if err := r1.TryRLock(ctx); !errors.Is(err, ErrFailed) {
+		t.Fatalf("TryRLock() with writer = %v, want ErrFailed", err)
+	}
+	if err := w.Extend(); err != nil {
+		t.Fatalf("Extend() = %v", err)
+	}
+	if err := w.Unlock(); err != nil {
+		t.Fatalf("Unlock() = %v", err)
+	}
+	if err := w.Unlock(); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("Unlock() twice = %v, want ErrLockLost", err)
+	}
+}
+
+func TestRWMutex_WriterPreferring(t *testing.T) {
+	mr, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	reader := rs.NewRWMutex(ctx, "rw")
+	if err := reader.RLock(); err != nil {
+		t.Fatal(err)
+	}
+
+	writer := rs.NewRWMutex(ctx, "rw", SetToken("writer"),
+		SetTries(1000), SetRetryDelay(5*time.Millisecond, 10*time.Millisecond))
+	locked := make(chan error, 1)
+	go func() {
+		locked <- writer.Lock()
+	}()
+
+	// 等待写者登记后，新的读者被阻止
+	deadline := time.Now().Add(time.Second)
+	for !mr.Exists("rw") || mr.HGet("rw", pendingPrefix+"writer") == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("writer is not pending")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := rs.NewRWMutex(ctx, "rw").TryRLock(ctx); !errors.Is(err, ErrFailed) {
+		t.Fatalf("TryRLock() with pending writer = %v, want ErrFailed", err)
+	}
+
+	if err := reader.RUnlock(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-locked:
+		if err != nil {
+			t.Fatalf("Lock() = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("writer is not granted after readers left")
+	}
+	if err := writer.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists("rw") {
+		t.Error("lock key is left after all holders left")
+	}
+}
+
+func TestRWMutex_WriterGiveUp(t *testing.T) {
+	_, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	reader := rs.NewRWMutex(ctx, "rw")
+	if err := reader.RLock(); err != nil {
+		t.Fatal(err)
+	}
+	defer reader.RUnlock()
+
+	if err := rs.NewRWMutex(ctx, "rw").TryLock(ctx); !errors.Is(err, ErrFailed) {
+		t.Fatalf("TryLock() = %v, want ErrFailed", err)
+	}
+	if err := rs.NewRWMutex(ctx, "rw").TryRLock(ctx); err != nil {
+		t.Fatalf("TryRLock() after the writer gave up = %v", err)
+	}
+}
+
+func TestRWMutex_Expiry(t *testing.T) {
+	mr, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	w := rs.NewRWMutex(ctx, "rw", SetExpiry(50*time.Millisecond))
+	if err := w.Lock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 持有者的过期时间按 redis 服务器时钟判断
+	mr.SetTime(time.Now().Add(time.Second))
+	if err := rs.NewRWMutex(ctx, "rw").TryRLock(ctx); err != nil {
+		t.Fatalf("TryRLock() after the writer expired = %v", err)
+	}
+	if err := w.Extend(); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("Extend() after expiry = %v, want ErrLockLost", err)
+	}
+}
@@ -0,0 +1,88 @@
+package redsync
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// semaphorePrefix is the field prefix of the holders of a Semaphore
+const semaphorePrefix = "s:"
+
+var semaphoreAcquireScript = redis.NewScript(hashLockPrelude + `
+	local n = 0
+	for _ in pairs(live) do
+		n = n + 1
+	end
+	if not live[ARGV[1]] and n >= tonumber(ARGV[3]) then
+		return 0
+	end
+	hold(ARGV[1], ARGV[2])
+	return 1
+`)
+
+// Semaphore is a distributed counting semaphore, at most permits holders hold it at the same time.
+// Every Semaphore value is one holder, a permit is released on Release or after the expiry.
+type Semaphore struct {
+	mutex   *Mutex
+	permits int
+}
+
+// NewSemaphore returns a new distributed semaphore with given name and permits,
+// the options configure the holder like a mutex, SetFencing is not supported.
+func (r *Redsync) NewSemaphore(ctx context.Context, name string, permits int, options ...Option) *Semaphore {
+	ops := &hashOps{
+		prefix:        semaphorePrefix,
+		acquireScript: semaphoreAcquireScript,
+		args:          []any{permits},
+	}
+	return &Semaphore{
+		mutex:   r.newMutex(ctx, name, ops, options),
+		permits: permits,
+	}
+}
+
+// NewSemaphore create a semaphore using default redsync
+func NewSemaphore(ctx context.Context, name string, permits int, options ...Option) *Semaphore {
+	return defaultRedsync.NewSemaphore(ctx, name, permits, options...)
+}
+
+// Permits return the max holders of s
+func (s *Semaphore) Permits() int {
+	return s.permits
+}
+
+// Acquire acquires a permit with the context of the semaphore, see AcquireContext.
+func (s *Semaphore) Acquire() error {
+	return s.mutex.Lock()
+}
+
+// AcquireContext acquires a permit, it has the semantics of Mutex.LockContext.
+func (s *Semaphore) AcquireContext(ctx context.Context) error {
+	return s.mutex.LockContext(ctx)
+}
+
+// TryAcquire makes a single attempt to acquire a permit, it has the semantics of Mutex.TryLock.
+func (s *Semaphore) TryAcquire(ctx context.Context) error {
+	return s.mutex.TryLock(ctx)
+}
+
+// Release releases the permit, it has the semantics of Mutex.Unlock.
+func (s *Semaphore) Release() error {
+	return s.mutex.Unlock()
+}
+
+// Extend resets the permit's expiry, it has the semantics of Mutex.Extend.
+func (s *Semaphore) Extend() error {
+	return s.mutex.Extend()
+}
+
+// Lost return a channel closed when the watchdog fails to renew the permit, see Mutex.Lost.
+func (s *Semaphore) Lost() <-chan struct{} {
+	return s.mutex.Lost()
+}
+
+// Context return a context canceled when the permit is lost or released, see Mutex.Context.
+func (s *Semaphore) Context() context.Context {
+	return s.mutex.Context()
+}
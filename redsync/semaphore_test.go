@@ -0,0 +1,84 @@
+package redsync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSemaphore(t *testing.T) {
+	mr, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	var holders []*Semaphore
+	for i := 0; i < 3; i++ {
+		s := rs.NewSemaphore(ctx, "sem", 3)
+		if err := s.TryAcquire(ctx); err != nil {
+			t.Fatalf("TryAcquire() of permit %d = %v", i, err)
+		}
+		holders = append(holders, s)
+	}
+
+	s := rs.NewSemaphore(ctx, "sem", 3)
+	if err := s.TryAcquire(ctx); !errors.Is(err, ErrFailed) {
+		t.Fatalf("TryAcquire() without permits = %v, want ErrFailed", err)
+	}
+
+	if err := holders[0].Release(); err != nil {
+		t.Fatalf("Release() = %v", err)
+	}
+	if err := s.TryAcquire(ctx); err != nil {
+		t.Fatalf("TryAcquire() after release = %v", err)
+	}
+	if err := s.Extend(); err != nil {
+		t.Fatalf("Extend() = %v", err)
+	}
+
+	for _, h := range append(holders[1:], s) {
+		if err := h.Release(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if mr.Exists("sem") {
+		t.Error("semaphore key is left after all holders left")
+	}
+}
+
+func TestSemaphore_Expiry(t *testing.T) {
+	mr, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	holder := rs.NewSemaphore(ctx, "sem", 1, SetExpiry(50*time.Millisecond))
+	if err := holder.Acquire(); err != nil {
+		t.Fatal(err)
+	}
+
+	mr.SetTime(time.Now().Add(time.Second))
+	s := rs.NewSemaphore(ctx, "sem", 1)
+	if err := s.TryAcquire(ctx); err != nil {
+		t.Fatalf("TryAcquire() after the holder expired = %v", err)
+	}
+	if err := holder.Release(); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("Release() of the expired holder = %v, want ErrLockLost", err)
+	}
+}
+
+func TestSemaphore_AcquireContext(t *testing.T) {
+	_, rs := newTestRedsync(t)
+
+	holder := rs.NewSemaphore(context.Background(), "sem", 1)
+	if err := holder.Acquire(); err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(30*time.Millisecond, func() {
+		_ = holder.Release()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s := rs.NewSemaphore(ctx, "sem", 1, SetRetryDelay(5*time.Millisecond, 10*time.Millisecond))
+	if err := s.AcquireContext(ctx); err != nil {
+		t.Fatalf("AcquireContext() = %v", err)
+	}
+}
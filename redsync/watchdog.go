@@ -0,0 +1,138 @@
+package redsync
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// watcher renews a held lock in background until it is stopped or the lock is lost
+type watcher struct {
+	ctx      context.Context
+	cancel   context.CancelCauseFunc
+	lost     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func (w *watcher) signalStop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+}
+
+// SetWatchdog enable the watchdog which extends the lock every expiry/3 while it is held,
+// so that the critical section is not limited by the expiry. See Mutex.Lost and Mutex.Context.
+func SetWatchdog(enabled bool) Option {
+	return OptionFunc(func(m *Mutex) {
+		m.watchdog = enabled
+	})
+}
+
+// Lost return a channel closed when the watchdog fails to renew the lock,
+// the protected work should abort since the lock may be taken by others.
+// It returns nil if the watchdog is disabled or the mutex has never been locked.
+func (m *Mutex) Lost() <-chan struct{} {
+	if w := m.watch.Load(); w != nil {
+		return w.lost
+	}
+	return nil
+}
+
+// Context return a context derived from the locking context, it is canceled with cause ErrLockLost
+// when the watchdog fails to renew the lock, and canceled on Unlock.
+// It returns the context of the mutex if the watchdog is disabled or the mutex has never been locked.
+// In the reentrant mode the returned context carries the token once locked, see SetReentrant.
+func (m *Mutex) Context() context.Context {
+	if w := m.watch.Load(); w != nil {
+		return w.ctx
+	}
+	if ctx := m.tokenCtx.Load(); ctx != nil {
+		return *ctx
+	}
+	return m.ctx
+}
+
+// startWatchdog start renewing the acquired lock, the caller must hold nodeMutex
+func (m *Mutex) startWatchdog(ctx context.Context) {
+	if !m.watchdog {
+		return
+	}
+
+	w := &watcher{
+		lost: make(chan struct{}),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	w.ctx, w.cancel = context.WithCancelCause(ctx)
+	if old := m.watch.Swap(w); old != nil {
+		// 重复加锁：通知旧的 watcher 退出但不等待，它可能正阻塞在调用方持有的 nodeMutex 上
+		old.signalStop()
+		old.cancel(context.Canceled)
+	}
+	go m.renew(w)
+}
+
+// stopWatchdog stop renewing and wait the watcher to exit, the caller must not hold nodeMutex
+func (m *Mutex) stopWatchdog() {
+	w := m.watch.Load()
+	if w == nil {
+		return
+	}
+
+	w.signalStop()
+	<-w.done
+	w.cancel(context.Canceled)
+}
+
+func (m *Mutex) renew(w *watcher) {
+	defer close(w.done)
+
+	interval := max(m.expiry/3, time.Millisecond)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+
+		if err := m.renewOnce(w, interval); err != nil {
+			w.cancel(err)
+			close(w.lost)
+			return
+		}
+	}
+}
+
+// renewOnce extend the lock, a redis error is tolerated while the lock stays valid until the next renewal.
+// The returned error wraps ErrLockLost once the lock is considered lost.
+func (m *Mutex) renewOnce(w *watcher, interval time.Duration) error {
+	m.nodeMutex.Lock()
+	defer m.nodeMutex.Unlock()
+
+	select {
+	case <-w.stop:
+		return nil
+	default:
+	}
+
+	// 续期不受加锁 context 取消的影响，只由 Unlock 停止
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(w.ctx), interval)
+	defer cancel()
+
+	err := m.extend(ctx)
+	if err == nil || (errors.Is(err, ErrUnreachable) && time.Now().Add(interval).Before(m.until)) {
+		return nil
+	}
+
+	m.releaseAll(ctx)
+	if !errors.Is(err, ErrLockLost) {
+		err = errors.Join(ErrLockLost, err)
+	}
+	return err
+}
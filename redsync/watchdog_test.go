@@ -0,0 +1,149 @@
+package redsync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWatchdog_Renew(t *testing.T) {
+	mr, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	m := rs.NewMutex(ctx, "lock", SetExpiry(300*time.Millisecond), SetWatchdog(true))
+	if err := m.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Unlock()
+
+	// miniredis 的过期时间只随 FastForward 推进
+	mr.FastForward(250 * time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for mr.TTL("lock") <= 50*time.Millisecond {
+		if time.Now().After(deadline) {
+			t.Fatalf("lock is not renewed, ttl = %v", mr.TTL("lock"))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case <-m.Lost():
+		t.Fatal("Lost() closed while the lock is held")
+	default:
+	}
+}
+
+func TestWatchdog_Lost(t *testing.T) {
+	mr, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	m := rs.NewMutex(ctx, "lock", SetExpiry(300*time.Millisecond), SetWatchdog(true))
+	if err := m.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.Set("lock", "other"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-m.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("Lost() is not closed after the lock is taken by others")
+	}
+	if err := context.Cause(m.Context()); !errors.Is(err, ErrLockLost) {
+		t.Errorf("context cause = %v, want ErrLockLost", err)
+	}
+	if v, _ := mr.Get("lock"); v != "other" {
+		t.Errorf("lock value = %q, the other holder must be kept", v)
+	}
+}
+
+func TestWatchdog_Expired(t *testing.T) {
+	mr, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	m := rs.NewMutex(ctx, "lock", SetExpiry(300*time.Millisecond), SetWatchdog(true))
+	if err := m.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	// 锁过期后被别人拿走又释放，续期不能悄悄把锁重新拿回来
+	mr.FastForward(time.Second)
+
+	select {
+	case <-m.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("Lost() is not closed after the lock expired")
+	}
+	if err := context.Cause(m.Context()); !errors.Is(err, ErrLockLost) {
+		t.Errorf("context cause = %v, want ErrLockLost", err)
+	}
+	if mr.Exists("lock") {
+		t.Error("the expired lock is taken again by the renewal")
+	}
+}
+
+func TestWatchdog_Unreachable(t *testing.T) {
+	mr, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	m := rs.NewMutex(ctx, "lock", SetExpiry(300*time.Millisecond), SetWatchdog(true))
+	if err := m.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	mr.Close()
+
+	select {
+	case <-m.Context().Done():
+	case <-time.After(time.Second):
+		t.Fatal("Context() is not canceled after redis is unreachable")
+	}
+	err := context.Cause(m.Context())
+	if !errors.Is(err, ErrLockLost) || !errors.Is(err, ErrUnreachable) {
+		t.Errorf("context cause = %v, want ErrLockLost and ErrUnreachable", err)
+	}
+}
+
+func TestWatchdog_Unlock(t *testing.T) {
+	mr, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	m := rs.NewMutex(ctx, "lock", SetExpiry(300*time.Millisecond), SetWatchdog(true))
+	if err := m.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	lockCtx := m.Context()
+	if err := m.Unlock(); err != nil {
+		t.Fatalf("Unlock() = %v", err)
+	}
+
+	if !errors.Is(context.Cause(lockCtx), context.Canceled) {
+		t.Errorf("context cause after Unlock = %v", context.Cause(lockCtx))
+	}
+	select {
+	case <-m.Lost():
+		t.Error("Lost() closed after Unlock")
+	default:
+	}
+	if mr.Exists("lock") {
+		t.Error("lock is not released")
+	}
+}
+
+func TestWatchdog_Disabled(t *testing.T) {
+	_, rs := newTestRedsync(t)
+	ctx := context.Background()
+
+	m := rs.NewMutex(ctx, "lock")
+	if err := m.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Unlock()
+
+	if m.Lost() != nil {
+		t.Error("Lost() != nil without watchdog")
+	}
+	if m.Context() != ctx {
+		t.Error("Context() is not the mutex context without watchdog")
+	}
+}
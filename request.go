@@ -12,4 +12,6 @@ type Request struct {
 
 	RestVars httprouter.Params
 	RawBody  []byte
+	// Route is the registered pattern matching the request, e.g. `/users/:id`
+	Route string
 }
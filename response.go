@@ -1,6 +1,7 @@
 package kate
 
 import (
+	"bytes"
 	"net/http"
 )
 
@@ -53,3 +54,29 @@ func (w *responseWriter) Flush() {
 	flusher := w.ResponseWriter.(http.Flusher)
 	flusher.Flush()
 }
+
+// bodyRecorder records the body written through it, RawBody only keeps the last Write.
+// Only the first limit bytes are kept if limit > 0, size counts all the written bytes.
+type bodyRecorder struct {
+	ResponseWriter
+	limit int
+	size  int
+	body  bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	keep := b[:n]
+	if w.limit > 0 {
+		keep = keep[:min(n, max(w.limit-w.body.Len(), 0))]
+	}
+	w.body.Write(keep)
+	return n, err
+}
+
+func (w *bodyRecorder) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -56,3 +56,8 @@ func httpStatusOf(errInfo ErrorInfo) int {
 	}
 	return http.StatusInternalServerError
 }
+
+// writeError 供中间件按 RESTHandler 契约渲染错误（真实状态码 + errno envelope）。
+func writeError(ctx context.Context, w http.ResponseWriter, err error) {
+	(&RESTHandler{}).Error(ctx, w, err)
+}
@@ -33,7 +33,16 @@ func (r *RESTRouter) SetMaxBodyBytes(n int64) {
 
 // Handle register a http handler for the specified method and path
 func (r *RESTRouter) Handle(method, pattern string, h ContextHandler) {
-	r.Router.Handle(method, pattern, Handle(r.ctx, h, r.maxBodyBytes))
+	r.Router.Handle(method, pattern, Handle(r.ctx, withRoute(pattern, h), r.maxBodyBytes))
+}
+
+// withRoute records the pattern in Request.Route, httprouter does not expose the matched pattern
+func withRoute(pattern string, h ContextHandler) ContextHandler {
+	f := func(ctx context.Context, w ResponseWriter, r *Request) {
+		r.Route = pattern
+		h.ServeHTTP(ctx, w, r)
+	}
+	return ContextHandlerFunc(f)
 }
 
 // HandleFunc register a http handler for the specified method and path
@@ -41,6 +50,13 @@ func (r *RESTRouter) HandleFunc(method, pattern string, h func(context.Context,
 	r.Handle(method, pattern, ContextHandlerFunc(h))
 }
 
+// GlobalOPTIONS register the handler for automatic OPTIONS responses, it is called for
+// any path having other methods registered, with the `Allow` header already set.
+// Typically used to answer CORS preflight requests.
+func (r *RESTRouter) GlobalOPTIONS(h ContextHandler) {
+	r.Router.GlobalOPTIONS = StdHandler(r.ctx, h, r.maxBodyBytes)
+}
+
 // HEAD register a handler for HEAD request
 func (r *RESTRouter) HEAD(pattern string, h ContextHandler) {
 	r.Handle("HEAD", pattern, h)
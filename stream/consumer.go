@@ -0,0 +1,345 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stn81/kate/log"
+	"github.com/stn81/kate/rdb"
+	"github.com/stn81/kate/taskengine"
+	"github.com/stn81/kate/traceid"
+	"go.uber.org/zap"
+)
+
+// Dead letter fields added to the original values
+const (
+	FieldDeadId       = "_dead_id"
+	FieldDeadStream   = "_dead_stream"
+	FieldDeadError    = "_dead_error"
+	FieldDeadAttempts = "_dead_attempts"
+)
+
+// ConsumerConfig is the config of Consumer
+type ConsumerConfig struct {
+	Stream           string
+	Group            string
+	Consumer         string        // consumer name, unique in the group
+	Concurrency      int           // max handlers running concurrently, default 1
+	BatchSize        int64         // max messages per read, default Concurrency
+	Block            time.Duration // max blocking time per read, bounds the Stop latency, default 2s
+	MaxAttempts      int           // handler runs before dead-lettering, default 5
+	MinBackoff       time.Duration // backoff before the first retry, doubled per retry, default 100ms
+	MaxBackoff       time.Duration // max backoff, default 10s
+	ClaimInterval    time.Duration // interval to reclaim stuck messages, default 30s
+	ClaimMinIdle     time.Duration // messages pending longer than it are reclaimed, must exceed the handling time with retries, default 5m
+	DeadLetterStream string        // default Stream + ":dead"
+	DeadLetterMaxLen int64         // 0 means no trimming
+}
+
+// Consumer consumes a stream in a consumer group, handlers run on a TaskEngine.
+// A message is acked after the handler succeeds, failures are retried in process with backoff,
+// messages left pending by crashed consumers are reclaimed, and messages failing MaxAttempts
+// times are moved to the dead letter stream.
+type Consumer struct {
+	client  rdb.Client
+	conf    ConsumerConfig
+	handler Handler
+	logger  *zap.Logger
+	engine  *taskengine.TaskEngine
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewConsumer create a consumer
+func NewConsumer(ctx context.Context, client rdb.Client, conf *ConsumerConfig, handler Handler, logger *zap.Logger) *Consumer {
+	c := &Consumer{
+		client:  client,
+		conf:    *conf,
+		handler: handler,
+	}
+	if c.conf.Concurrency <= 0 {
+		c.conf.Concurrency = 1
+	}
+	if c.conf.BatchSize <= 0 {
+		c.conf.BatchSize = int64(c.conf.Concurrency)
+	}
+	if c.conf.Block <= 0 {
+		c.conf.Block = 2 * time.Second
+	}
+	if c.conf.MaxAttempts <= 0 {
+		c.conf.MaxAttempts = 5
+	}
+	if c.conf.MinBackoff <= 0 {
+		c.conf.MinBackoff = 100 * time.Millisecond
+	}
+	if c.conf.MaxBackoff < c.conf.MinBackoff {
+		c.conf.MaxBackoff = max(10*time.Second, c.conf.MinBackoff)
+	}
+	if c.conf.ClaimInterval <= 0 {
+		c.conf.ClaimInterval = 30 * time.Second
+	}
+	if c.conf.ClaimMinIdle <= 0 {
+		c.conf.ClaimMinIdle = 5 * time.Minute
+	}
+	if c.conf.DeadLetterStream == "" {
+		c.conf.DeadLetterStream = c.conf.Stream + ":dead"
+	}
+
+	c.logger = logger.With(
+		zap.String("stream", c.conf.Stream),
+		zap.String("group", c.conf.Group),
+		zap.String("consumer", c.conf.Consumer),
+	)
+	c.engine = taskengine.New(ctx, "stream:"+c.conf.Stream, c.conf.Concurrency, c.logger)
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	return c
+}
+
+// Start create the group (and the stream) if absent, then start consuming
+func (c *Consumer) Start() error {
+	err := c.client.XGroupCreateMkStream(c.ctx, c.conf.Stream, c.conf.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("create group: %w", err)
+	}
+
+	c.wg.Add(2)
+	go c.readLoop()
+	go c.claimLoop()
+	c.logger.Info("consumer started")
+	return nil
+}
+
+// Stop stop reading new messages and wait the running handlers with TaskEngine.Shutdown semantics:
+// the handler context is canceled, unacked messages stay pending and will be reclaimed.
+func (c *Consumer) Stop() {
+	c.cancel()
+	c.wg.Wait()
+	c.engine.Shutdown()
+	c.logger.Info("consumer stopped")
+}
+
+func (c *Consumer) readLoop() {
+	defer c.wg.Done()
+
+	for c.ctx.Err() == nil {
+		streams, err := c.client.XReadGroup(c.ctx, &redis.XReadGroupArgs{
+			Group:    c.conf.Group,
+			Consumer: c.conf.Consumer,
+			Streams:  []string{c.conf.Stream, ">"},
+			Count:    c.conf.BatchSize,
+			Block:    c.conf.Block,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) || c.ctx.Err() != nil {
+				continue
+			}
+			c.logger.Error("read stream failed", zap.Error(err))
+			c.sleep(c.conf.MinBackoff)
+			continue
+		}
+
+		for _, s := range streams {
+			for _, xmsg := range s.Messages {
+				c.dispatch(xmsg, 1)
+			}
+		}
+	}
+}
+
+func (c *Consumer) claimLoop() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.conf.ClaimInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.claim()
+		}
+	}
+}
+
+// claim take over the messages pending longer than ClaimMinIdle
+func (c *Consumer) claim() {
+	start := "0-0"
+	for c.ctx.Err() == nil {
+		msgs, next, err := c.client.XAutoClaim(c.ctx, &redis.XAutoClaimArgs{
+			Stream:   c.conf.Stream,
+			Group:    c.conf.Group,
+			Consumer: c.conf.Consumer,
+			MinIdle:  c.conf.ClaimMinIdle,
+			Start:    start,
+			Count:    c.conf.BatchSize,
+		}).Result()
+		if err != nil {
+			if c.ctx.Err() == nil {
+				c.logger.Error("claim stuck messages failed", zap.Error(err))
+			}
+			return
+		}
+
+		for _, xmsg := range msgs {
+			c.logger.Warn("reclaimed stuck message", zap.String("id", xmsg.ID))
+			c.dispatch(xmsg, c.deliveryCount(xmsg.ID))
+		}
+
+		if next == "0-0" || next == "" {
+			return
+		}
+		start = next
+	}
+}
+
+// deliveryCount return the times the message has been delivered, including the current delivery
+func (c *Consumer) deliveryCount(id string) int {
+	pending, err := c.client.XPendingExt(c.ctx, &redis.XPendingExtArgs{
+		Stream: c.conf.Stream,
+		Group:  c.conf.Group,
+		Start:  id,
+		End:    id,
+		Count:  1,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return 1
+	}
+	return int(pending[0].RetryCount)
+}
+
+func (c *Consumer) dispatch(xmsg redis.XMessage, attempt int) {
+	msg := &Message{
+		Id:      xmsg.ID,
+		Stream:  c.conf.Stream,
+		Values:  xmsg.Values,
+		Attempt: attempt,
+	}
+	err := c.engine.ScheduleContext(c.ctx, taskengine.TaskFunc(func(ctx context.Context) {
+		c.process(ctx, msg)
+	}))
+	if err != nil {
+		// 停机中：消息保持 pending，由其他消费者或重启后回收
+		c.logger.Info("message left pending", zap.String("id", msg.Id), zap.Error(err))
+	}
+}
+
+func (c *Consumer) process(ctx context.Context, msg *Message) {
+	ctx = c.messageContext(ctx, msg)
+	logger := log.GetLogger(ctx)
+
+	var err error
+	for ; msg.Attempt <= c.conf.MaxAttempts; msg.Attempt++ {
+		if err = c.handle(ctx, msg); err == nil {
+			c.ack(ctx, msg)
+			return
+		}
+		if msg.Attempt == c.conf.MaxAttempts {
+			break
+		}
+
+		backoff := c.backoff(msg.Attempt)
+		logger.Warn("handle message failed, retrying",
+			zap.Int("attempt", msg.Attempt), zap.Duration("backoff", backoff), zap.Error(err))
+		if !c.sleepContext(ctx, backoff) {
+			// 停机中：保持 pending，由其他消费者或重启后回收
+			return
+		}
+	}
+
+	if err == nil {
+		err = fmt.Errorf("delivered %d times", msg.Attempt)
+	}
+	c.deadLetter(ctx, msg, err)
+}
+
+func (c *Consumer) handle(ctx context.Context, msg *Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.GetLogger(ctx).Error("handler panic", zap.Any("error", r), zap.Stack("stack"))
+			err = fmt.Errorf("handler panic: %v", r)
+		}
+	}()
+	return c.handler.Handle(ctx, msg)
+}
+
+func (c *Consumer) ack(ctx context.Context, msg *Message) {
+	// 使用独立 context，停机时已处理完成的消息仍能 ack
+	ctx = context.WithoutCancel(ctx)
+	if err := c.client.XAck(ctx, c.conf.Stream, c.conf.Group, msg.Id).Err(); err != nil {
+		log.GetLogger(ctx).Error("ack message failed", zap.Error(err))
+	}
+}
+
+func (c *Consumer) deadLetter(ctx context.Context, msg *Message, cause error) {
+	ctx = context.WithoutCancel(ctx)
+	logger := log.GetLogger(ctx)
+
+	values := make(map[string]any, len(msg.Values)+4)
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	values[FieldDeadId] = msg.Id
+	values[FieldDeadStream] = msg.Stream
+	values[FieldDeadAttempts] = msg.Attempt
+	values[FieldDeadError] = cause.Error()
+
+	if _, err := xadd(ctx, c.client, c.conf.DeadLetterStream, c.conf.DeadLetterMaxLen, values); err != nil {
+		logger.Error("dead-letter message failed", zap.Error(err))
+		return
+	}
+	logger.Error("message dead-lettered", zap.Int("attempts", msg.Attempt), zap.Error(cause))
+	c.ack(ctx, msg)
+}
+
+// messageContext restore the producer trace and attach the message logger
+func (c *Consumer) messageContext(ctx context.Context, msg *Message) context.Context {
+	traceId := ""
+	if tp, ok := msg.Values[FieldTraceparent].(string); ok {
+		delete(msg.Values, FieldTraceparent)
+		if sc, err := traceid.ParseTraceparent(tp); err == nil {
+			traceId = sc.TraceId
+			ctx = traceid.WithSpanContext(ctx, sc)
+		}
+	}
+	if traceId == "" {
+		traceId = traceid.New()
+	}
+	ctx = traceid.ToContext(ctx, traceId)
+
+	logger := c.logger.With(zap.String("trace_id", traceId), zap.String("id", msg.Id))
+	return log.ToContext(ctx, logger)
+}
+
+// backoff return the delay after the failed attempt, the exponential backoff with equal jitter
+func (c *Consumer) backoff(attempt int) time.Duration {
+	d := c.conf.MinBackoff << min(attempt-1, 16)
+	if d <= 0 || d > c.conf.MaxBackoff {
+		d = c.conf.MaxBackoff
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+func (c *Consumer) sleep(d time.Duration) {
+	c.sleepContext(c.ctx, d)
+}
+
+func (c *Consumer) sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,83 @@
+// Package stream implements producers and consumer groups on redis streams.
+package stream
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stn81/kate/rdb"
+	"github.com/stn81/kate/traceid"
+)
+
+// FieldTraceparent is the message field carrying the W3C traceparent of the producer,
+// it is removed from Message.Values on consume.
+const FieldTraceparent = "_traceparent"
+
+// Message is a stream entry delivered to the handler
+type Message struct {
+	Id      string
+	Stream  string
+	Values  map[string]any
+	Attempt int // 1-based attempt, a reclaimed message starts from its delivery count
+}
+
+// Handler handles the messages, a nil error acks the message
+type Handler interface {
+	Handle(ctx context.Context, msg *Message) error
+}
+
+// HandlerFunc is the func adapter of Handler
+type HandlerFunc func(ctx context.Context, msg *Message) error
+
+// Handle implements Handler
+func (f HandlerFunc) Handle(ctx context.Context, msg *Message) error {
+	return f(ctx, msg)
+}
+
+// Producer appends messages to a stream
+type Producer struct {
+	client rdb.Client
+	stream string
+	maxLen int64
+}
+
+// NewProducer create the producer of stream, the stream is trimmed to about maxLen entries, 0 means no trimming
+func NewProducer(client rdb.Client, stream string, maxLen int64) *Producer {
+	return &Producer{
+		client: client,
+		stream: stream,
+		maxLen: maxLen,
+	}
+}
+
+// Publish append a message with values and return its id, the trace in ctx is propagated to the consumer
+func (p *Producer) Publish(ctx context.Context, values map[string]any) (string, error) {
+	return xadd(ctx, p.client, p.stream, p.maxLen, withTraceparent(ctx, values))
+}
+
+func xadd(ctx context.Context, client rdb.Client, stream string, maxLen int64, values map[string]any) (string, error) {
+	return client.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: maxLen,
+		Approx: maxLen > 0,
+		Values: values,
+	}).Result()
+}
+
+func withTraceparent(ctx context.Context, values map[string]any) map[string]any {
+	header := make(http.Header)
+	traceid.Inject(ctx, header)
+
+	tp := header.Get(traceid.HeaderTraceparent)
+	if tp == "" {
+		return values
+	}
+
+	copied := make(map[string]any, len(values)+1)
+	for k, v := range values {
+		copied[k] = v
+	}
+	copied[FieldTraceparent] = tp
+	return copied
+}
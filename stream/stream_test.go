@@ -0,0 +1,210 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stn81/kate/traceid"
+	"go.uber.org/zap"
+)
+
+func newTestClient(t *testing.T) *redis.Client {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return client
+}
+
+func testConfig() *ConsumerConfig {
+	return &ConsumerConfig{
+		Stream:      "jobs",
+		Group:       "workers",
+		Consumer:    "c1",
+		Concurrency: 2,
+		Block:       20 * time.Millisecond,
+		MaxAttempts: 3,
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  2 * time.Millisecond,
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func pendingCount(t *testing.T, client *redis.Client) int64 {
+	pending, err := client.XPending(context.Background(), "jobs", "workers").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pending.Count
+}
+
+func TestConsumer_AckAndRetry(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	var (
+		mu       sync.Mutex
+		attempts = make(map[string]int)
+		done     = make(map[string]bool)
+	)
+	handler := HandlerFunc(func(ctx context.Context, msg *Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		name := msg.Values["name"].(string)
+		attempts[name] = msg.Attempt
+		if name == "flaky" && msg.Attempt < 3 {
+			return errors.New("temporary")
+		}
+		done[name] = true
+		return nil
+	})
+
+	consumer := NewConsumer(ctx, client, testConfig(), handler, zap.NewNop())
+	if err := consumer.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	producer := NewProducer(client, "jobs", 1000)
+	for _, name := range []string{"ok", "flaky"} {
+		if _, err := producer.Publish(ctx, map[string]any{"name": name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return done["ok"] && done["flaky"]
+	})
+	consumer.Stop()
+
+	if attempts["ok"] != 1 || attempts["flaky"] != 3 {
+		t.Errorf("attempts = %v", attempts)
+	}
+	if n := pendingCount(t, client); n != 0 {
+		t.Errorf("pending = %d, want all acked", n)
+	}
+}
+
+func TestConsumer_DeadLetter(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	handler := HandlerFunc(func(ctx context.Context, msg *Message) error {
+		panic("always broken")
+	})
+	consumer := NewConsumer(ctx, client, testConfig(), handler, zap.NewNop())
+	if err := consumer.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	id, _ := NewProducer(client, "jobs", 0).Publish(ctx, map[string]any{"name": "poison"})
+
+	var dead []redis.XMessage
+	waitFor(t, func() bool {
+		dead, _ = client.XRange(ctx, "jobs:dead", "-", "+").Result()
+		return len(dead) == 1
+	})
+	consumer.Stop()
+
+	values := dead[0].Values
+	if values[FieldDeadId] != id || values["name"] != "poison" || values[FieldDeadAttempts] != "3" ||
+		values[FieldDeadError] != "handler panic: always broken" {
+		t.Errorf("dead letter = %v", values)
+	}
+	if n := pendingCount(t, client); n != 0 {
+		t.Errorf("pending = %d, dead-lettered message should be acked", n)
+	}
+}
+
+func TestConsumer_ReclaimStuck(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	if err := client.XGroupCreateMkStream(ctx, "jobs", "workers", "0").Err(); err != nil {
+		t.Fatal(err)
+	}
+	id, _ := NewProducer(client, "jobs", 0).Publish(ctx, map[string]any{"name": "stuck"})
+
+	// 崩溃的消费者读取后未 ack
+	if err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: "workers", Consumer: "crashed", Streams: []string{"jobs", ">"}, Count: 1,
+	}).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(chan *Message, 1)
+	conf := testConfig()
+	conf.ClaimInterval = 20 * time.Millisecond
+	conf.ClaimMinIdle = 10 * time.Millisecond
+	consumer := NewConsumer(ctx, client, conf, HandlerFunc(func(ctx context.Context, msg *Message) error {
+		got <- msg
+		return nil
+	}), zap.NewNop())
+	if err := consumer.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Stop()
+
+	select {
+	case msg := <-got:
+		if msg.Id != id || msg.Attempt != 2 {
+			t.Errorf("reclaimed message = %+v, want delivery count 2", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stuck message not reclaimed")
+	}
+	waitFor(t, func() bool {
+		return pendingCount(t, client) == 0
+	})
+}
+
+func TestConsumer_TracePropagation(t *testing.T) {
+	client := newTestClient(t)
+
+	remote, _ := traceid.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := traceid.WithSpanContext(traceid.ToContext(context.Background(), remote.TraceId), remote)
+
+	got := make(chan string, 1)
+	consumer := NewConsumer(context.Background(), client, testConfig(), HandlerFunc(func(ctx context.Context, msg *Message) error {
+		if _, ok := msg.Values[FieldTraceparent]; ok {
+			t.Error("traceparent field should be removed")
+		}
+		got <- traceid.Extract(ctx)
+		return nil
+	}), zap.NewNop())
+	if err := consumer.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Stop()
+
+	if _, err := NewProducer(client, "jobs", 0).Publish(ctx, map[string]any{"name": "traced"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case traceId := <-got:
+		if traceId != remote.TraceId {
+			t.Errorf("trace id = %q, want %q", traceId, remote.TraceId)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not consumed")
+	}
+}
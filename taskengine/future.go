@@ -0,0 +1,89 @@
+package taskengine
+
+import (
+	"context"
+	"sync"
+)
+
+// Future is the pending result of a task scheduled by Submit
+type Future[T any] struct {
+	done chan struct{}
+	once sync.Once
+	val  T
+	err  error
+}
+
+func newFuture[T any]() *Future[T] {
+	return &Future[T]{done: make(chan struct{})}
+}
+
+func (f *Future[T]) resolve(val T, err error) {
+	f.once.Do(func() {
+		f.val, f.err = val, err
+		close(f.done)
+	})
+}
+
+// Done return a channel closed when the result is ready
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait wait the result of the task, it returns ctx.Err() if ctx is done first
+func (f *Future[T]) Wait(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Submit schedule fn on engine like ScheduleContext and return its future.
+// A scheduling failure, a panic wrapped by ErrPanic, or ErrDropped in queue mode is the error of the future,
+// the errors are also passed to the error handler of the engine.
+func Submit[T any](ctx context.Context, engine *TaskEngine, fn func(ctx context.Context) (T, error)) *Future[T] {
+	task := &futureTask[T]{
+		engine: engine,
+		future: newFuture[T](),
+		fn:     fn,
+	}
+	if err := engine.ScheduleContext(ctx, task); err != nil {
+		var zero T
+		task.future.resolve(zero, err)
+	}
+	return task.future
+}
+
+type futureTask[T any] struct {
+	engine *TaskEngine
+	future *Future[T]
+	fn     func(ctx context.Context) (T, error)
+}
+
+// Run implements Task
+func (t *futureTask[T]) Run(ctx context.Context) {
+	var (
+		val T
+		err error
+	)
+	defer func() {
+		if r := recover(); r != nil {
+			err = t.engine.panicked(r)
+		}
+		if err != nil {
+			t.engine.handleError(ctx, err)
+		}
+		t.future.resolve(val, err)
+	}()
+
+	val, err = t.fn(ctx)
+}
+
+// Drop implements Droppable
+func (t *futureTask[T]) Drop(err error) {
+	var zero T
+	t.engine.handleError(t.engine.ctx, err)
+	t.future.resolve(zero, err)
+}
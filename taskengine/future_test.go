@@ -0,0 +1,95 @@
+package taskengine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestSubmit(t *testing.T) {
+	engine := New(context.Background(), "test", 2, zap.NewNop())
+	defer engine.Shutdown()
+
+	var (
+		mu       sync.Mutex
+		reported []error
+	)
+	engine.SetErrorHandler(func(ctx context.Context, err error) {
+		mu.Lock()
+		reported = append(reported, err)
+		mu.Unlock()
+	})
+
+	ctx := context.Background()
+	ok := Submit(ctx, engine, func(ctx context.Context) (int, error) { return 42, nil })
+	if v, err := ok.Wait(ctx); err != nil || v != 42 {
+		t.Errorf("Wait() = %d, %v", v, err)
+	}
+
+	errBoom := errors.New("boom")
+	failed := Submit(ctx, engine, func(ctx context.Context) (int, error) { return 0, errBoom })
+	if _, err := failed.Wait(ctx); !errors.Is(err, errBoom) {
+		t.Errorf("Wait() = %v, want errBoom", err)
+	}
+
+	panicked := Submit(ctx, engine, func(ctx context.Context) (string, error) { panic("oops") })
+	if _, err := panicked.Wait(ctx); !errors.Is(err, ErrPanic) {
+		t.Errorf("Wait() = %v, want ErrPanic", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reported) != 2 || !errors.Is(reported[0], errBoom) || !errors.Is(reported[1], ErrPanic) {
+		t.Errorf("reported errors = %v", reported)
+	}
+}
+
+func TestFuture_WaitContext(t *testing.T) {
+	engine := New(context.Background(), "test", 1, zap.NewNop())
+	defer engine.Shutdown()
+
+	release := make(chan struct{})
+	future := Submit(context.Background(), engine, func(ctx context.Context) (int, error) {
+		<-release
+		return 1, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := future.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait() = %v, want context.DeadlineExceeded", err)
+	}
+
+	close(release)
+	<-future.Done()
+	if v, err := future.Wait(context.Background()); err != nil || v != 1 {
+		t.Errorf("Wait() = %d, %v", v, err)
+	}
+}
+
+func TestSubmit_NotScheduled(t *testing.T) {
+	engine := NewQueued(context.Background(), "test",
+		&QueueConfig{Workers: 1, QueueSize: 1, Overflow: OverflowDropOldest}, zap.NewNop())
+
+	release := blockWorkers(t, engine, 1)
+	dropped := Submit(context.Background(), engine, func(ctx context.Context) (int, error) { return 1, nil })
+	kept := Submit(context.Background(), engine, func(ctx context.Context) (int, error) { return 2, nil })
+	if _, err := dropped.Wait(context.Background()); !errors.Is(err, ErrDropped) {
+		t.Errorf("Wait() of the dropped task = %v, want ErrDropped", err)
+	}
+
+	release()
+	if v, err := kept.Wait(context.Background()); err != nil || v != 2 {
+		t.Errorf("Wait() = %d, %v", v, err)
+	}
+
+	engine.Shutdown()
+	rejected := Submit(context.Background(), engine, func(ctx context.Context) (int, error) { return 3, nil })
+	if _, err := rejected.Wait(context.Background()); !errors.Is(err, ErrShutdown) {
+		t.Errorf("Wait() after shutdown = %v, want ErrShutdown", err)
+	}
+}
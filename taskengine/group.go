@@ -0,0 +1,88 @@
+package taskengine
+
+import (
+	"context"
+	"sync"
+)
+
+// Group runs related tasks on an engine with errgroup semantics: the first error cancels
+// the group context and is returned by Wait. The concurrency is bounded by the engine.
+type Group struct {
+	engine *TaskEngine
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	wg     sync.WaitGroup
+	once   sync.Once
+	err    error
+}
+
+// NewGroup create a group running on engine, the returned context is canceled
+// when a task fails or Wait returns.
+func NewGroup(ctx context.Context, engine *TaskEngine) (*Group, context.Context) {
+	g := &Group{engine: engine}
+	g.ctx, g.cancel = context.WithCancelCause(ctx)
+	return g, g.ctx
+}
+
+// Go schedule fn in the group, it blocks while the engine is saturated like ScheduleContext.
+// fn gets the group context, which is also canceled on the engine shutdown.
+// A scheduling failure fails the group. Tasks of the group must not call Go on a saturated engine,
+// otherwise they wait for themselves.
+func (g *Group) Go(fn func(ctx context.Context) error) {
+	g.wg.Add(1)
+	task := &groupTask{group: g, fn: fn}
+	if err := g.engine.ScheduleContext(g.ctx, task); err != nil {
+		g.fail(err)
+		g.wg.Done()
+	}
+}
+
+// Wait wait all the tasks of the group to return and return the first error
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel(nil)
+	return g.err
+}
+
+func (g *Group) fail(err error) {
+	g.once.Do(func() {
+		g.err = err
+		g.cancel(err)
+	})
+}
+
+type groupTask struct {
+	group *Group
+	fn    func(ctx context.Context) error
+}
+
+// Run implements Task
+func (t *groupTask) Run(engineCtx context.Context) {
+	g := t.group
+	defer g.wg.Done()
+
+	ctx, cancel := context.WithCancel(g.ctx)
+	defer cancel()
+	stop := context.AfterFunc(engineCtx, cancel)
+	defer stop()
+
+	var err error
+	defer func() {
+		if r := recover(); r != nil {
+			err = g.engine.panicked(r)
+		}
+		if err != nil {
+			g.engine.handleError(ctx, err)
+			g.fail(err)
+		}
+	}()
+
+	err = t.fn(ctx)
+}
+
+// Drop implements Droppable
+func (t *groupTask) Drop(err error) {
+	t.group.engine.handleError(t.group.ctx, err)
+	t.group.fail(err)
+	t.group.wg.Done()
+}
@@ -0,0 +1,106 @@
+package taskengine
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestGroup(t *testing.T) {
+	engine := New(context.Background(), "test", 2, zap.NewNop())
+	defer engine.Shutdown()
+
+	var (
+		running atomic.Int64
+		peak    atomic.Int64
+		sum     atomic.Int64
+	)
+	g, _ := NewGroup(context.Background(), engine)
+	for i := 1; i <= 10; i++ {
+		g.Go(func(ctx context.Context) error {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			sum.Add(int64(i))
+			running.Add(-1)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+	if sum.Load() != 55 {
+		t.Errorf("sum = %d, want 55", sum.Load())
+	}
+	if peak.Load() > 2 {
+		t.Errorf("peak concurrency = %d, exceeds the engine concurrency 2", peak.Load())
+	}
+}
+
+func TestGroup_FirstErrorCancels(t *testing.T) {
+	engine := New(context.Background(), "test", 4, zap.NewNop())
+	defer engine.Shutdown()
+
+	var reported atomic.Int64
+	engine.SetErrorHandler(func(ctx context.Context, err error) {
+		reported.Add(1)
+	})
+
+	errBoom := errors.New("boom")
+	g, ctx := NewGroup(context.Background(), engine)
+	g.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	g.Go(func(ctx context.Context) error {
+		return errBoom
+	})
+
+	if err := g.Wait(); !errors.Is(err, errBoom) {
+		t.Fatalf("Wait() = %v, want errBoom", err)
+	}
+	if !errors.Is(context.Cause(ctx), errBoom) {
+		t.Errorf("group context cause = %v, want errBoom", context.Cause(ctx))
+	}
+	if reported.Load() != 1 {
+		t.Errorf("reported %d errors, want 1", reported.Load())
+	}
+}
+
+func TestGroup_Panic(t *testing.T) {
+	engine := New(context.Background(), "test", 1, zap.NewNop())
+	defer engine.Shutdown()
+
+	g, _ := NewGroup(context.Background(), engine)
+	g.Go(func(ctx context.Context) error {
+		panic("oops")
+	})
+	if err := g.Wait(); !errors.Is(err, ErrPanic) {
+		t.Fatalf("Wait() = %v, want ErrPanic", err)
+	}
+}
+
+func TestGroup_EngineShutdown(t *testing.T) {
+	engine := New(context.Background(), "test", 1, zap.NewNop())
+
+	g, _ := NewGroup(context.Background(), engine)
+	g.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	time.Sleep(5 * time.Millisecond)
+	engine.Shutdown()
+
+	if err := g.Wait(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait() = %v, want context.Canceled", err)
+	}
+}
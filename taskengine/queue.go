@@ -0,0 +1,213 @@
+package taskengine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrDropped is passed to Droppable tasks evicted from the queue by OverflowDropOldest
+var ErrDropped = errors.New("taskengine: dropped")
+
+// Priority is the priority of queued tasks, workers always take the highest priority task first
+type Priority int
+
+// priorities
+const (
+	PriorityHigh Priority = iota
+	PriorityNormal
+	PriorityLow
+
+	numPriorities = 3
+)
+
+// OverflowPolicy decides what happens when scheduling on a full queue
+type OverflowPolicy int
+
+// overflow policies
+const (
+	OverflowBlock      OverflowPolicy = iota // wait for room, TrySchedule returns ErrFull
+	OverflowReject                           // return ErrFull
+	OverflowDropOldest                       // evict the oldest queued task of the same priority
+)
+
+// Droppable is implemented by tasks which must know they are evicted from the queue without running
+type Droppable interface {
+	Drop(err error)
+}
+
+// QueueConfig is the config of the queue mode
+type QueueConfig struct {
+	Workers   int            // fixed number of workers, default 1
+	QueueSize int            // capacity of each priority, default 1024
+	Overflow  OverflowPolicy // default OverflowBlock
+}
+
+type queuedTask struct {
+	task     Task
+	enqueued time.Time
+}
+
+// queue holds the bounded priority queues of the queue mode
+type queue struct {
+	levels  [numPriorities]chan *queuedTask
+	policy  OverflowPolicy
+	workers int
+	pushers sync.WaitGroup
+	stop    chan struct{}
+}
+
+// NewQueued create a task engine in queue mode: a fixed worker pool runs the tasks from
+// bounded priority queues, so that a burst of tasks queues up instead of spawning goroutines.
+// On Shutdown the queued tasks still run, with the canceled context.
+func NewQueued(ctx context.Context, name string, conf *QueueConfig, logger *zap.Logger) *TaskEngine {
+	engine := New(ctx, name, 0, logger)
+
+	q := &queue{
+		policy:  conf.Overflow,
+		workers: max(conf.Workers, 1),
+		stop:    make(chan struct{}),
+	}
+	size := conf.QueueSize
+	if size <= 0 {
+		size = 1024
+	}
+	for i := range q.levels {
+		q.levels[i] = make(chan *queuedTask, size)
+	}
+	engine.queue = q
+
+	engine.workers.Add(q.workers)
+	for i := 0; i < q.workers; i++ {
+		go engine.work()
+	}
+	return engine
+}
+
+// ScheduleWithPriority schedule a task with priority, in the default mode the priority is ignored.
+// It returns ctx.Err() if ctx is done while blocking, ErrFull if rejected by the overflow policy,
+// or ErrShutdown if the engine is shut down.
+func (engine *TaskEngine) ScheduleWithPriority(ctx context.Context, task Task, priority Priority) error {
+	if engine.queue == nil {
+		return engine.ScheduleContext(ctx, task)
+	}
+	return engine.enqueue(ctx, task, priority, engine.queue.policy)
+}
+
+func (engine *TaskEngine) enqueue(ctx context.Context, task Task, priority Priority, policy OverflowPolicy) error {
+	if err := engine.enter(); err != nil {
+		return err
+	}
+	defer engine.queue.pushers.Done()
+
+	item := &queuedTask{task: task, enqueued: time.Now()}
+	ch := engine.queue.levels[min(max(priority, PriorityHigh), PriorityLow)]
+
+	select {
+	case ch <- item:
+		engine.stats.submitted.Add(1)
+		return nil
+	default:
+	}
+
+	switch policy {
+	case OverflowBlock:
+		select {
+		case ch <- item:
+			engine.stats.submitted.Add(1)
+			return nil
+		case <-ctx.Done():
+			engine.Done()
+			return ctx.Err()
+		case <-engine.ctx.Done():
+			engine.Done()
+			return ErrShutdown
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case ch <- item:
+				engine.stats.submitted.Add(1)
+				return nil
+			default:
+			}
+			select {
+			case old := <-ch:
+				engine.drop(old)
+			default:
+			}
+		}
+	default:
+		engine.stats.rejected.Add(1)
+		engine.Done()
+		return ErrFull
+	}
+}
+
+func (engine *TaskEngine) drop(item *queuedTask) {
+	engine.stats.dropped.Add(1)
+	engine.logger.Warn("queue full, dropped the oldest task")
+	if d, ok := item.task.(Droppable); ok {
+		d.Drop(ErrDropped)
+	}
+	engine.Done()
+}
+
+func (engine *TaskEngine) work() {
+	defer engine.workers.Done()
+
+	for {
+		item := engine.queue.next()
+		if item == nil {
+			return
+		}
+
+		engine.stats.waitNanos.Add(int64(time.Since(item.enqueued)))
+		engine.runTask(item.task)
+		engine.Done()
+	}
+}
+
+// next return the highest priority task, it blocks until a task is queued, and returns nil
+// once the queue is stopped and drained
+func (q *queue) next() *queuedTask {
+	for {
+		for _, ch := range q.levels {
+			select {
+			case item := <-ch:
+				return item
+			default:
+			}
+		}
+
+		select {
+		case item := <-q.levels[PriorityHigh]:
+			return item
+		case item := <-q.levels[PriorityNormal]:
+			return item
+		case item := <-q.levels[PriorityLow]:
+			return item
+		case <-q.stop:
+			// 停机后排空队列再退出
+			for _, ch := range q.levels {
+				select {
+				case item := <-ch:
+					return item
+				default:
+				}
+			}
+			return nil
+		}
+	}
+}
+
+func (q *queue) depth() []int {
+	depth := make([]int, numPriorities)
+	for i, ch := range q.levels {
+		depth[i] = len(ch)
+	}
+	return depth
+}
@@ -0,0 +1,193 @@
+package taskengine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type droppableTask struct {
+	TaskFunc
+	dropped chan error
+}
+
+func (t *droppableTask) Drop(err error) {
+	t.dropped <- err
+}
+
+// blockWorkers occupy all the workers of engine until the returned func is called
+func blockWorkers(t *testing.T, engine *TaskEngine, workers int) func() {
+	t.Helper()
+
+	var (
+		started sync.WaitGroup
+		release = make(chan struct{})
+	)
+	started.Add(workers)
+	for i := 0; i < workers; i++ {
+		if err := engine.ScheduleWithPriority(context.Background(), TaskFunc(func(ctx context.Context) {
+			started.Done()
+			<-release
+		}), PriorityHigh); err != nil {
+			t.Fatal(err)
+		}
+	}
+	started.Wait()
+	return func() { close(release) }
+}
+
+func TestQueue_Priority(t *testing.T) {
+	engine := NewQueued(context.Background(), "test", &QueueConfig{Workers: 1}, zap.NewNop())
+	release := blockWorkers(t, engine, 1)
+
+	var (
+		mu    sync.Mutex
+		order []Priority
+	)
+	for _, p := range []Priority{PriorityLow, PriorityNormal, PriorityHigh, PriorityLow, PriorityHigh} {
+		if err := engine.ScheduleWithPriority(context.Background(), TaskFunc(func(ctx context.Context) {
+			mu.Lock()
+			order = append(order, p)
+			mu.Unlock()
+		}), p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := engine.Stats(); stats.QueueDepth() != 5 || stats.Queued[PriorityHigh] != 2 {
+		t.Fatalf("Stats() = %+v", stats)
+	}
+
+	release()
+	engine.Shutdown()
+
+	want := []Priority{PriorityHigh, PriorityHigh, PriorityNormal, PriorityLow, PriorityLow}
+	if len(order) != len(want) {
+		t.Fatalf("order = %v", order)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestQueue_OverflowReject(t *testing.T) {
+	engine := NewQueued(context.Background(), "test",
+		&QueueConfig{Workers: 1, QueueSize: 1, Overflow: OverflowReject}, zap.NewNop())
+	defer engine.Shutdown()
+
+	release := blockWorkers(t, engine, 1)
+	defer release()
+
+	if err := engine.TrySchedule(TaskFunc(func(ctx context.Context) {})); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.ScheduleContext(context.Background(), TaskFunc(func(ctx context.Context) {})); !errors.Is(err, ErrFull) {
+		t.Fatalf("ScheduleContext() on full queue = %v, want ErrFull", err)
+	}
+	if engine.Schedule(TaskFunc(func(ctx context.Context) {})) {
+		t.Fatal("Schedule() on full queue = true")
+	}
+	if stats := engine.Stats(); stats.Rejected != 2 {
+		t.Errorf("Rejected = %d, want 2", stats.Rejected)
+	}
+}
+
+func TestQueue_OverflowBlock(t *testing.T) {
+	engine := NewQueued(context.Background(), "test",
+		&QueueConfig{Workers: 1, QueueSize: 1, Overflow: OverflowBlock}, zap.NewNop())
+	defer engine.Shutdown()
+
+	release := blockWorkers(t, engine, 1)
+	if err := engine.ScheduleContext(context.Background(), TaskFunc(func(ctx context.Context) {})); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.TrySchedule(TaskFunc(func(ctx context.Context) {})); !errors.Is(err, ErrFull) {
+		t.Fatalf("TrySchedule() on full queue = %v, want ErrFull", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := engine.ScheduleContext(ctx, TaskFunc(func(ctx context.Context) {})); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ScheduleContext() on full queue = %v, want context.DeadlineExceeded", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- engine.ScheduleContext(context.Background(), TaskFunc(func(ctx context.Context) {}))
+	}()
+	release()
+	if err := <-done; err != nil {
+		t.Fatalf("blocked ScheduleContext() = %v", err)
+	}
+}
+
+func TestQueue_OverflowDropOldest(t *testing.T) {
+	engine := NewQueued(context.Background(), "test",
+		&QueueConfig{Workers: 1, QueueSize: 1, Overflow: OverflowDropOldest}, zap.NewNop())
+	defer engine.Shutdown()
+
+	release := blockWorkers(t, engine, 1)
+
+	oldest := &droppableTask{TaskFunc: func(ctx context.Context) {}, dropped: make(chan error, 1)}
+	if err := engine.ScheduleContext(context.Background(), oldest); err != nil {
+		t.Fatal(err)
+	}
+	ran := make(chan struct{})
+	if err := engine.TrySchedule(TaskFunc(func(ctx context.Context) { close(ran) })); err != nil {
+		t.Fatalf("TrySchedule() with drop oldest = %v", err)
+	}
+
+	if err := <-oldest.dropped; !errors.Is(err, ErrDropped) {
+		t.Errorf("Drop() = %v, want ErrDropped", err)
+	}
+	release()
+	<-ran
+	if stats := engine.Stats(); stats.Dropped != 1 {
+		t.Errorf("Dropped = %d, want 1", stats.Dropped)
+	}
+}
+
+func TestQueue_ShutdownDrains(t *testing.T) {
+	engine := NewQueued(context.Background(), "test", &QueueConfig{Workers: 2}, zap.NewNop())
+
+	var (
+		mu       sync.Mutex
+		ran      int
+		canceled int
+	)
+	for i := 0; i < 10; i++ {
+		engine.Schedule(TaskFunc(func(ctx context.Context) {
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			ran++
+			if ctx.Err() != nil {
+				canceled++
+			}
+			mu.Unlock()
+		}))
+	}
+	engine.Shutdown()
+
+	if ran != 10 {
+		t.Errorf("ran %d tasks, want 10", ran)
+	}
+	if canceled == 0 {
+		t.Error("queued tasks did not see the canceled context on shutdown")
+	}
+	if err := engine.TrySchedule(TaskFunc(func(ctx context.Context) {})); !errors.Is(err, ErrShutdown) {
+		t.Errorf("TrySchedule() after shutdown = %v, want ErrShutdown", err)
+	}
+
+	stats := engine.Stats()
+	if stats.Workers != 2 || stats.Submitted != 10 || stats.Completed != 10 || stats.Running != 0 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+	if stats.RunTime < 10*time.Millisecond || stats.AvgRunTime() < time.Millisecond {
+		t.Errorf("RunTime = %v, AvgRunTime = %v", stats.RunTime, stats.AvgRunTime())
+	}
+}
@@ -0,0 +1,72 @@
+package taskengine
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of the engine statistics
+type Stats struct {
+	Workers   int           // workers in queue mode, 0 in the default mode
+	Running   int64         // tasks running now
+	Queued    []int         // queue depth by priority in queue mode
+	Submitted uint64        // tasks accepted
+	Completed uint64        // tasks returned, including panics
+	Rejected  uint64        // tasks rejected with ErrFull
+	Dropped   uint64        // tasks evicted by OverflowDropOldest
+	WaitTime  time.Duration // total time the completed tasks waited in the queue
+	RunTime   time.Duration // total run time of the completed tasks
+}
+
+// QueueDepth return the total number of queued tasks
+func (s *Stats) QueueDepth() int {
+	n := 0
+	for _, depth := range s.Queued {
+		n += depth
+	}
+	return n
+}
+
+// AvgWaitTime return the average time the completed tasks waited in the queue
+func (s *Stats) AvgWaitTime() time.Duration {
+	if s.Completed == 0 {
+		return 0
+	}
+	return s.WaitTime / time.Duration(s.Completed)
+}
+
+// AvgRunTime return the average run time of the completed tasks
+func (s *Stats) AvgRunTime() time.Duration {
+	if s.Completed == 0 {
+		return 0
+	}
+	return s.RunTime / time.Duration(s.Completed)
+}
+
+type stats struct {
+	running   atomic.Int64
+	submitted atomic.Uint64
+	completed atomic.Uint64
+	rejected  atomic.Uint64
+	dropped   atomic.Uint64
+	waitNanos atomic.Int64
+	runNanos  atomic.Int64
+}
+
+// Stats return the statistics of the engine
+func (engine *TaskEngine) Stats() Stats {
+	s := Stats{
+		Running:   engine.stats.running.Load(),
+		Submitted: engine.stats.submitted.Load(),
+		Completed: engine.stats.completed.Load(),
+		Rejected:  engine.stats.rejected.Load(),
+		Dropped:   engine.stats.dropped.Load(),
+		WaitTime:  time.Duration(engine.stats.waitNanos.Load()),
+		RunTime:   time.Duration(engine.stats.runNanos.Load()),
+	}
+	if engine.queue != nil {
+		s.Workers = engine.queue.workers
+		s.Queued = engine.queue.depth()
+	}
+	return s
+}
@@ -1,14 +1,29 @@
 package taskengine
 
 import (
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"context"
 
 	"go.uber.org/zap"
 )
 
+var (
+	// ErrShutdown is returned when scheduling on an engine which is shut down
+	ErrShutdown = errors.New("taskengine: shutdown")
+	// ErrFull is returned by TrySchedule when the engine is saturated
+	ErrFull = errors.New("taskengine: full")
+	// ErrPanic is wrapped by the errors of panicked tasks
+	ErrPanic = errors.New("taskengine: task panic")
+)
+
+// ErrorHandler handles the errors of tasks: the errors of Submit and Group tasks, and the recovered panics
+type ErrorHandler func(ctx context.Context, err error)
+
 // TaskEngine define the task engine
 type TaskEngine struct {
 	name              string
@@ -16,7 +31,12 @@ type TaskEngine struct {
 	ctx               context.Context
 	cancel            context.CancelFunc
 	logger            *zap.Logger
+	mu                sync.RWMutex
 	shutdown          bool
+	queue             *queue
+	workers           sync.WaitGroup
+	stats             stats
+	errorHandler      atomic.Pointer[ErrorHandler]
 	sync.WaitGroup
 }
 
@@ -37,54 +57,171 @@ func New(ctx context.Context, name string, concurrencyLevel int, logger *zap.Log
 	return engine
 }
 
-// Schedule a task running on engine
+// Schedule a task running on engine, it blocks while the engine is saturated
+// and returns false if the engine is shut down or the task is rejected by the overflow policy.
 func (engine *TaskEngine) Schedule(task Task) bool {
-	if engine.shutdown {
+	err := engine.ScheduleContext(context.Background(), task)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, ErrShutdown):
 		engine.logger.Error("already stopped, should not schedule new task")
-		return false
+	default:
+		engine.logger.Error("schedule task failed", zap.Error(err))
 	}
+	return false
+}
 
-	engine.Add(1)
+// ScheduleContext schedule a task running on engine, it blocks while the engine is saturated.
+// It returns ctx.Err() if ctx is done first, or ErrShutdown if the engine is shut down.
+// In queue mode the task is queued with PriorityNormal, see ScheduleWithPriority.
+func (engine *TaskEngine) ScheduleContext(ctx context.Context, task Task) error {
+	if engine.queue != nil {
+		return engine.enqueue(ctx, task, PriorityNormal, engine.queue.policy)
+	}
+	if err := engine.enter(); err != nil {
+		return err
+	}
 
 	if engine.concurrencyTokens != nil {
-		engine.concurrencyTokens <- struct{}{}
+		select {
+		case engine.concurrencyTokens <- struct{}{}:
+		case <-ctx.Done():
+			engine.Done()
+			return ctx.Err()
+		case <-engine.ctx.Done():
+			engine.Done()
+			return ErrShutdown
+		}
 	}
 
+	engine.stats.submitted.Add(1)
 	go engine.run(task)
-	return true
+	return nil
+}
+
+// TrySchedule schedule a task running on engine without blocking, it returns nil if the task is scheduled.
+// Unlike Schedule it returns an error instead of a bool so that callers can tell why with errors.Is:
+// ErrFull if the engine is saturated, or ErrShutdown if the engine is shut down.
+// In queue mode the task is queued with PriorityNormal, OverflowBlock is taken as OverflowReject.
+func (engine *TaskEngine) TrySchedule(task Task) error {
+	if engine.queue != nil {
+		policy := engine.queue.policy
+		if policy == OverflowBlock {
+			policy = OverflowReject
+		}
+		return engine.enqueue(context.Background(), task, PriorityNormal, policy)
+	}
+	if err := engine.enter(); err != nil {
+		return err
+	}
+
+	if engine.concurrencyTokens != nil {
+		select {
+		case engine.concurrencyTokens <- struct{}{}:
+		default:
+			engine.stats.rejected.Add(1)
+			engine.Done()
+			return ErrFull
+		}
+	}
+
+	engine.stats.submitted.Add(1)
+	go engine.run(task)
+	return nil
+}
+
+// enter register a task unless the engine is shut down, the registered task must call Done.
+// In queue mode the caller is also registered as a pusher, which must call queue.pushers.Done.
+func (engine *TaskEngine) enter() error {
+	engine.mu.RLock()
+	defer engine.mu.RUnlock()
+
+	// 在读锁内 Add，保证 Shutdown 的 Wait 能看到所有已登记的任务
+	if engine.shutdown {
+		return ErrShutdown
+	}
+	engine.Add(1)
+	if engine.queue != nil {
+		engine.queue.pushers.Add(1)
+	}
+	return nil
 }
 
 func (engine *TaskEngine) run(task Task) {
 	defer func() {
-		if r := recover(); r != nil {
-			engine.logger.Error("task panic:",
-				zap.Any("error", r),
-				zap.Stack("stack"),
-			)
-		}
 		if engine.concurrencyTokens != nil {
 			<-engine.concurrencyTokens
 		}
 		engine.Done()
 	}()
 
+	engine.runTask(task)
+}
+
+// runTask run the task with panic recovered and the stats updated
+func (engine *TaskEngine) runTask(task Task) {
+	start := time.Now()
+	engine.stats.running.Add(1)
+	defer func() {
+		if r := recover(); r != nil {
+			engine.handleError(engine.ctx, engine.panicked(r))
+		}
+		engine.stats.running.Add(-1)
+		engine.stats.completed.Add(1)
+		engine.stats.runNanos.Add(int64(time.Since(start)))
+	}()
+
 	task.Run(engine.ctx)
 }
 
-// Shutdown stop the task engine
+// SetErrorHandler set the handler of task errors, nil removes it
+func (engine *TaskEngine) SetErrorHandler(handler ErrorHandler) {
+	if handler == nil {
+		engine.errorHandler.Store(nil)
+		return
+	}
+	engine.errorHandler.Store(&handler)
+}
+
+func (engine *TaskEngine) handleError(ctx context.Context, err error) {
+	if handler := engine.errorHandler.Load(); handler != nil {
+		(*handler)(ctx, err)
+	}
+}
+
+// panicked log the recovered panic and return it as an error wrapping ErrPanic
+func (engine *TaskEngine) panicked(r any) error {
+	engine.logger.Error("task panic:",
+		zap.Any("error", r),
+		zap.Stack("stack"),
+	)
+	return fmt.Errorf("%w: %v", ErrPanic, r)
+}
+
+// Shutdown stop the task engine: new tasks are rejected, the context of running tasks is canceled,
+// and it waits the running tasks to return.
 func (engine *TaskEngine) Shutdown() {
+	engine.mu.Lock()
 	if engine.shutdown {
+		engine.mu.Unlock()
 		panic(fmt.Sprintf("task engine %s shutdown twice", engine.name))
 	}
+	engine.shutdown = true
+	engine.mu.Unlock()
 
 	engine.logger.Info("stopping")
 
-	engine.shutdown = true
+	// 取消 context 同时唤醒阻塞在 ScheduleContext 中的调用方；token channel 不关闭，
+	// 避免与并发的调度发生 send on closed channel
 	engine.cancel()
+	if engine.queue != nil {
+		// 等待进行中的入队结束后通知 worker 排空队列退出
+		engine.queue.pushers.Wait()
+		close(engine.queue.stop)
+		engine.workers.Wait()
+	}
 	engine.WaitGroup.Wait()
 
-	if engine.concurrencyTokens != nil {
-		close(engine.concurrencyTokens)
-	}
 	engine.logger.Info("stopped")
 }
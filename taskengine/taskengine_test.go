@@ -0,0 +1,95 @@
+package taskengine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestTaskEngine_TrySchedule(t *testing.T) {
+	engine := New(context.Background(), "test", 1, zap.NewNop())
+	defer engine.Shutdown()
+
+	release := make(chan struct{})
+	if err := engine.TrySchedule(TaskFunc(func(ctx context.Context) { <-release })); err != nil {
+		t.Fatalf("TrySchedule() = %v", err)
+	}
+	if err := engine.TrySchedule(TaskFunc(func(ctx context.Context) {})); !errors.Is(err, ErrFull) {
+		t.Fatalf("TrySchedule() on saturated engine = %v, want ErrFull", err)
+	}
+
+	close(release)
+	engine.Wait()
+	if err := engine.TrySchedule(TaskFunc(func(ctx context.Context) {})); err != nil {
+		t.Fatalf("TrySchedule() after the task done = %v", err)
+	}
+}
+
+func TestTaskEngine_ScheduleContext(t *testing.T) {
+	engine := New(context.Background(), "test", 1, zap.NewNop())
+	defer engine.Shutdown()
+
+	release := make(chan struct{})
+	defer close(release)
+	if err := engine.ScheduleContext(context.Background(), TaskFunc(func(ctx context.Context) { <-release })); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := engine.ScheduleContext(ctx, TaskFunc(func(ctx context.Context) {})); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ScheduleContext() on saturated engine = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestTaskEngine_Shutdown(t *testing.T) {
+	engine := New(context.Background(), "test", 1, zap.NewNop())
+
+	canceled := make(chan struct{})
+	engine.Schedule(TaskFunc(func(ctx context.Context) {
+		<-ctx.Done()
+		close(canceled)
+	}))
+
+	// 阻塞中的调度在停机时返回 ErrShutdown
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- engine.ScheduleContext(context.Background(), TaskFunc(func(ctx context.Context) {}))
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	engine.Shutdown()
+	<-canceled
+	if err := <-blocked; !errors.Is(err, ErrShutdown) {
+		t.Errorf("blocked ScheduleContext() = %v, want ErrShutdown", err)
+	}
+	if err := engine.TrySchedule(TaskFunc(func(ctx context.Context) {})); !errors.Is(err, ErrShutdown) {
+		t.Errorf("TrySchedule() after shutdown = %v, want ErrShutdown", err)
+	}
+	if engine.Schedule(TaskFunc(func(ctx context.Context) {})) {
+		t.Error("Schedule() after shutdown = true")
+	}
+}
+
+func TestTaskEngine_ScheduleRacingShutdown(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		engine := New(context.Background(), "test", 2, zap.NewNop())
+
+		var wg sync.WaitGroup
+		for j := 0; j < 8; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for k := 0; k < 20; k++ {
+					_ = engine.ScheduleContext(context.Background(), TaskFunc(func(ctx context.Context) {}))
+				}
+			}()
+		}
+		engine.Shutdown()
+		wg.Wait()
+	}
+}
@@ -0,0 +1,276 @@
+package timerengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/stn81/kate/log"
+	"github.com/stn81/kate/taskengine"
+	"go.uber.org/zap"
+)
+
+// ErrStopped is returned by a retry task when its retry can't be scheduled on the stopped timer engine
+var ErrStopped = errors.New("timerengine: stopped")
+
+// RetryPolicy define how a failed task is retried
+type RetryPolicy struct {
+	MaxAttempts    int                  // max attempts including the first one, default 3
+	MinBackoff     time.Duration        // backoff before the first retry, default 1s
+	MaxBackoff     time.Duration        // max backoff, default 1m
+	Multiplier     float64              // backoff multiplier per retry, default 2
+	Jitter         float64              // randomize the backoff by ±Jitter*backoff, 0 means no jitter
+	Retryable      func(err error) bool // report whether err is retryable, nil means all errors except Permanent
+	AttemptTimeout time.Duration        // timeout of each attempt, 0 means no timeout
+}
+
+func (p *RetryPolicy) withDefaults() *RetryPolicy {
+	policy := *p
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 3
+	}
+	if policy.MinBackoff <= 0 {
+		policy.MinBackoff = time.Second
+	}
+	if policy.MaxBackoff < policy.MinBackoff {
+		policy.MaxBackoff = max(time.Minute, policy.MinBackoff)
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = 2
+	}
+	policy.Jitter = min(max(policy.Jitter, 0), 1)
+	return &policy
+}
+
+// Backoff return the delay before retrying the failed attempt, the attempt starts from 1
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	p = p.withDefaults()
+
+	d := float64(p.MinBackoff)
+	for i := 1; i < attempt && d < float64(p.MaxBackoff); i++ {
+		d *= p.Multiplier
+	}
+	d = min(d, float64(p.MaxBackoff))
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wrap err so that it is never retried
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+type attemptKey struct{}
+
+// AttemptFromContext return the attempt of the retry task running with ctx, the attempt starts from 1.
+// It returns 0 if ctx is not of a retry task.
+func AttemptFromContext(ctx context.Context) int {
+	attempt, _ := ctx.Value(attemptKey{}).(int)
+	return attempt
+}
+
+// RetryTask wrap a func into a task retried by policy, it implements both Task and taskengine.Task.
+// The first attempt runs inline in Run, the retries are scheduled on the timer engine after the backoff
+// instead of sleeping in the worker, so the backoff is rounded up to whole seconds.
+// Each attempt gets the context of Run with the attempt, and the logger in it logs the attempt too.
+// The retries are canceled when the context of Run is done or the timer engine is stopped.
+type RetryTask struct {
+	timer  *TimerEngine
+	policy *RetryPolicy
+	fn     func(ctx context.Context) error
+
+	mu       sync.Mutex
+	ctx      context.Context
+	stop     func() bool
+	attempts int
+	pending  *TimerTask
+
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+// NewRetryTask create a task running fn with policy, retries are scheduled on timer
+func NewRetryTask(timer *TimerEngine, policy *RetryPolicy, fn func(ctx context.Context) error) *RetryTask {
+	if policy == nil {
+		policy = &RetryPolicy{}
+	}
+	return &RetryTask{
+		timer:  timer,
+		policy: policy.withDefaults(),
+		fn:     fn,
+		done:   make(chan struct{}),
+	}
+}
+
+// Run implements Task, a retry task runs only once
+func (t *RetryTask) Run(ctx context.Context) {
+	t.mu.Lock()
+	if t.ctx != nil {
+		t.mu.Unlock()
+		t.timer.logger.Warn("retry task is already run")
+		return
+	}
+	t.ctx = ctx
+	t.mu.Unlock()
+
+	t.stop = context.AfterFunc(ctx, t.abort)
+	t.attempt(ctx)
+}
+
+// Done return a channel closed when the task succeeds or gives up
+func (t *RetryTask) Done() <-chan struct{} {
+	return t.done
+}
+
+// Err return the final error, it is valid after Done is closed
+func (t *RetryTask) Err() error {
+	select {
+	case <-t.done:
+		return t.err
+	default:
+		return nil
+	}
+}
+
+// Wait wait the task to succeed or give up and return the final error, it returns ctx.Err() if ctx is done first
+func (t *RetryTask) Wait(ctx context.Context) error {
+	select {
+	case <-t.done:
+		return t.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Attempts return the number of the started attempts
+func (t *RetryTask) Attempts() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.attempts
+}
+
+func (t *RetryTask) attempt(ctx context.Context) {
+	t.mu.Lock()
+	t.attempts++
+	attempt := t.attempts
+	t.mu.Unlock()
+
+	err := t.call(ctx, attempt)
+	if err == nil {
+		t.finish(nil)
+		return
+	}
+	if !t.policy.retryable(err) {
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			err = permanent.err
+		}
+		t.finish(err)
+		return
+	}
+	if attempt >= t.policy.MaxAttempts || ctx.Err() != nil {
+		t.finish(err)
+		return
+	}
+
+	delay := t.policy.Backoff(attempt)
+	log.GetLogger(ctx).Info("task attempt failed, will retry",
+		zap.Int("attempt", attempt),
+		zap.Duration("backoff", delay),
+		zap.Error(err),
+	)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = t.timer.Schedule(TaskFunc(t.retry), delay)
+	if t.pending == nil {
+		t.finish(fmt.Errorf("%w: %w", ErrStopped, err))
+	}
+}
+
+// retry run the next attempt on the timer engine, the attempt is also canceled when the timer engine stops
+func (t *RetryTask) retry(timerCtx context.Context) {
+	t.mu.Lock()
+	t.pending = nil
+	t.mu.Unlock()
+
+	if err := t.ctx.Err(); err != nil {
+		t.finish(err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(t.ctx)
+	defer cancel()
+	stop := context.AfterFunc(timerCtx, cancel)
+	defer stop()
+
+	t.attempt(ctx)
+}
+
+func (t *RetryTask) call(ctx context.Context, attempt int) (err error) {
+	ctx = context.WithValue(ctx, attemptKey{}, attempt)
+	ctx = log.With(ctx, zap.Int("attempt", attempt))
+	if t.policy.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.policy.AttemptTimeout)
+		defer cancel()
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.GetLogger(ctx).Error("task panic:", zap.Any("error", r), zap.Stack("stack"))
+			err = fmt.Errorf("%w: %v", taskengine.ErrPanic, r)
+		}
+	}()
+	return t.fn(ctx)
+}
+
+// abort cancel the pending retry when the context of Run is done
+func (t *RetryTask) abort() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pending != nil && t.pending.Cancel() {
+		t.pending = nil
+		t.finish(t.ctx.Err())
+	}
+}
+
+func (t *RetryTask) finish(err error) {
+	t.once.Do(func() {
+		if t.stop != nil {
+			t.stop()
+		}
+		t.err = err
+		close(t.done)
+		if err != nil {
+			log.GetLogger(t.ctx).Warn("task gave up",
+				zap.Int("attempts", t.attempts),
+				zap.Error(err),
+			)
+		}
+	})
+}
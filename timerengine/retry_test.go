@@ -0,0 +1,188 @@
+package timerengine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stn81/kate/taskengine"
+	"go.uber.org/zap"
+)
+
+func newTestTimer(t *testing.T) *TimerEngine {
+	te := New("test", 2, zap.NewNop())
+	te.Start()
+	t.Cleanup(te.Stop)
+	return te
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := &RetryPolicy{MinBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, d := range want {
+		if backoff := policy.Backoff(i + 1); backoff != d {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, backoff, d)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if backoff := policy.Backoff(2); backoff < time.Second || backoff > 3*time.Second {
+			t.Fatalf("Backoff(2) = %v, out of 2s±50%%", backoff)
+		}
+	}
+}
+
+func TestRetryTask(t *testing.T) {
+	te := newTestTimer(t)
+
+	errTransient := errors.New("transient")
+	var attempts []int
+	task := NewRetryTask(te, &RetryPolicy{MinBackoff: time.Millisecond}, func(ctx context.Context) error {
+		attempts = append(attempts, AttemptFromContext(ctx))
+		if len(attempts) < 2 {
+			return errTransient
+		}
+		return nil
+	})
+	task.Run(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := task.Wait(ctx); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+	if len(attempts) != 2 || attempts[0] != 1 || attempts[1] != 2 {
+		t.Errorf("attempts = %v, want [1 2]", attempts)
+	}
+}
+
+func TestRetryTask_GiveUp(t *testing.T) {
+	te := newTestTimer(t)
+
+	errTransient := errors.New("transient")
+	errFatal := errors.New("fatal")
+	cases := []struct {
+		name     string
+		policy   *RetryPolicy
+		fn       func(ctx context.Context) error
+		err      error
+		attempts int
+	}{
+		{
+			name:     "max attempts",
+			policy:   &RetryPolicy{MaxAttempts: 2, MinBackoff: time.Millisecond},
+			fn:       func(ctx context.Context) error { return errTransient },
+			err:      errTransient,
+			attempts: 2,
+		},
+		{
+			name:     "permanent",
+			policy:   &RetryPolicy{MinBackoff: time.Millisecond},
+			fn:       func(ctx context.Context) error { return Permanent(errFatal) },
+			err:      errFatal,
+			attempts: 1,
+		},
+		{
+			name: "not retryable",
+			policy: &RetryPolicy{
+				MinBackoff: time.Millisecond,
+				Retryable:  func(err error) bool { return errors.Is(err, errTransient) },
+			},
+			fn:       func(ctx context.Context) error { return errFatal },
+			err:      errFatal,
+			attempts: 1,
+		},
+		{
+			name:     "panic",
+			policy:   &RetryPolicy{MaxAttempts: 1},
+			fn:       func(ctx context.Context) error { panic("oops") },
+			err:      taskengine.ErrPanic,
+			attempts: 1,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			task := NewRetryTask(te, c.policy, c.fn)
+			task.Run(context.Background())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := task.Wait(ctx); !errors.Is(err, c.err) {
+				t.Fatalf("Wait() = %v, want %v", err, c.err)
+			}
+			var permanent *permanentError
+			if errors.As(task.Err(), &permanent) {
+				t.Errorf("Err() = %#v, the permanent wrapper is not stripped", task.Err())
+			}
+			if task.Attempts() != c.attempts {
+				t.Errorf("Attempts() = %d, want %d", task.Attempts(), c.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryTask_AttemptTimeout(t *testing.T) {
+	te := newTestTimer(t)
+
+	task := NewRetryTask(te, &RetryPolicy{MaxAttempts: 1, AttemptTimeout: 10 * time.Millisecond},
+		func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	task.Run(context.Background())
+	if err := task.Err(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Err() = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestRetryTask_Cancel(t *testing.T) {
+	te := newTestTimer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	task := NewRetryTask(te, &RetryPolicy{MinBackoff: time.Minute}, func(ctx context.Context) error {
+		return errors.New("transient")
+	})
+	task.Run(ctx)
+	cancel()
+
+	select {
+	case <-task.Done():
+	case <-time.After(time.Second):
+		t.Fatal("pending retry is not canceled")
+	}
+	if !errors.Is(task.Err(), context.Canceled) {
+		t.Errorf("Err() = %v, want context.Canceled", task.Err())
+	}
+	if task.Attempts() != 1 {
+		t.Errorf("Attempts() = %d, want 1", task.Attempts())
+	}
+}
+
+func TestRetryTask_OnTaskEngine(t *testing.T) {
+	te := newTestTimer(t)
+	engine := taskengine.New(context.Background(), "test", 1, zap.NewNop())
+	defer engine.Shutdown()
+
+	var attempts int
+	task := NewRetryTask(te, &RetryPolicy{MinBackoff: time.Millisecond}, func(ctx context.Context) error {
+		attempts++
+		if attempts < 2 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+	if !engine.Schedule(task) {
+		t.Fatal("Schedule() = false")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := task.Wait(ctx); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+}
@@ -96,44 +96,55 @@ func (te *TimerEngine) loop() {
 		case <-te.ctx.Done():
 			return
 		case req := <-te.requests:
-			{
-				var (
-					tickIndex   = te.getTickIndex()
-					offset      = int(req.delayInSeconds) + int(tickIndex)
-					cycleNum    = offset / RingSize
-					bucketIndex = offset % RingSize
-					bucket      = te.buckets[bucketIndex]
-					task        = newTimerTask(te, cycleNum, req.task)
-				)
-				bucket.PushBack(task)
-
-				req.result <- task
-			}
+			req.result <- te.add(req)
 		case <-te.ticker:
-			{
-				var (
-					tickIndex = te.updateTickIndex()
-					tasks     = te.buckets[tickIndex]
-				)
-
-				go func(tasks *list.List, tickIndex uint32) {
-					var next *list.Element
-
-					for te := tasks.Front(); te != nil; te = next {
-						next = te.Next()
-						task := te.Value.(*TimerTask)
-
-						if task.ready() {
-							task.dispose()
-							tasks.Remove(te)
-						}
-					}
-				}(tasks, tickIndex)
-			}
+			tickIndex := te.updateTickIndex()
+			go te.expire(te.buckets[tickIndex])
 		}
 	}
 }
 
+// add put the task of req into the bucket of its delay
+func (te *TimerEngine) add(req *request) *TimerTask {
+	var (
+		tickIndex   = te.getTickIndex()
+		offset      = int(req.delayInSeconds) + int(tickIndex)
+		cycleNum    = cycles(req.delayInSeconds)
+		bucketIndex = offset % RingSize
+		bucket      = te.buckets[bucketIndex]
+		task        = newTimerTask(te, cycleNum, req.task)
+	)
+	bucket.PushBack(task)
+	return task
+}
+
+// expire dispose the ready tasks of the visited bucket
+func (te *TimerEngine) expire(tasks *list.List) {
+	var next *list.Element
+
+	for e := tasks.Front(); e != nil; e = next {
+		next = e.Next()
+		task := e.Value.(*TimerTask)
+
+		if task.ready() {
+			task.dispose()
+			tasks.Remove(e)
+		}
+	}
+}
+
+// delayTicks return the ticks of delay, rounded up to whole seconds.
+// A sub-second delay takes one tick, it must not land on the current bucket which has been visited.
+func delayTicks(delay time.Duration) int64 {
+	return max(int64((delay+time.Second-1)/time.Second), 1)
+}
+
+// cycles return the visits of the bucket until the task of delay ticks is ready,
+// the bucket is first visited within RingSize ticks and then every RingSize ticks
+func cycles(ticks int64) int {
+	return int((ticks + RingSize - 1) / RingSize)
+}
+
 func (te *TimerEngine) nextTaskId() uint64 {
 	return atomic.AddUint64(&te.taskIdSeq, 1)
 }
@@ -154,7 +165,7 @@ func (te *TimerEngine) execute(f TaskFunc) {
 	te.executors.Schedule(f)
 }
 
-// Schedule a timer task with delay
+// Schedule a timer task with delay, the delay is rounded up to whole seconds
 func (te *TimerEngine) Schedule(task Task, delay time.Duration) (timerTask *TimerTask) {
 	if delay <= 0 {
 		timerTask = newTimerTask(te, 0, task)
@@ -164,7 +175,7 @@ func (te *TimerEngine) Schedule(task Task, delay time.Duration) (timerTask *Time
 
 	req := &request{
 		task:           task,
-		delayInSeconds: int64(delay.Seconds()),
+		delayInSeconds: delayTicks(delay),
 		result:         make(chan *TimerTask, 1),
 	}
 
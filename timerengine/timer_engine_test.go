@@ -0,0 +1,101 @@
+package timerengine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestDelayTicks(t *testing.T) {
+	cases := []struct {
+		delay time.Duration
+		ticks int64
+	}{
+		{time.Millisecond, 1},
+		{500 * time.Millisecond, 1},
+		{time.Second, 1},
+		{1500 * time.Millisecond, 2},
+		{time.Hour, RingSize},
+	}
+	for _, c := range cases {
+		if ticks := delayTicks(c.delay); ticks != c.ticks {
+			t.Errorf("delayTicks(%v) = %d, want %d", c.delay, ticks, c.ticks)
+		}
+	}
+}
+
+func TestCycles(t *testing.T) {
+	cases := []struct {
+		ticks  int64
+		cycles int
+	}{
+		{1, 1},
+		{RingSize - 1, 1},
+		{RingSize, 1},
+		{RingSize + 1, 2},
+		{2*RingSize + 400, 3},
+	}
+	for _, c := range cases {
+		if cycles := cycles(c.ticks); cycles != c.cycles {
+			t.Errorf("cycles(%d) = %d, want %d", c.ticks, cycles, c.cycles)
+		}
+	}
+}
+
+// Schedule 的到期时间与 tick 位置无关：整秒的 delay 恰好在 delay 个 tick 后到期(含 RingSize 及其倍数)，
+// 不足一秒的部分向上取整
+func TestTimerEngine_ScheduleTimings(t *testing.T) {
+	te := New("test", 1, zap.NewNop())
+	defer te.executors.Shutdown()
+
+	delays := []time.Duration{
+		500 * time.Millisecond,
+		time.Second,
+		1500 * time.Millisecond,
+		time.Minute,
+		(RingSize - 1) * time.Second,
+		RingSize * time.Second,
+		(RingSize + 1) * time.Second,
+		2 * RingSize * time.Second,
+		(2*RingSize + 1) * time.Second,
+		3 * RingSize * time.Second,
+	}
+	for _, start := range []uint32{0, 1, RingSize / 2, RingSize - 1} {
+		for _, delay := range delays {
+			te.tickIndex = start
+			task := te.add(&request{delayInSeconds: delayTicks(delay)})
+
+			want := delayTicks(delay)
+			var ticks int64
+			for ticks = 1; ticks <= want; ticks++ {
+				te.expire(te.buckets[te.updateTickIndex()])
+				if task.started {
+					break
+				}
+			}
+			if ticks != want {
+				t.Errorf("start %d, delay %v: fired after %d ticks, want %d", start, delay, ticks, want)
+			}
+			for _, bucket := range te.buckets {
+				bucket.Init()
+			}
+		}
+	}
+}
+
+func TestTimerEngine_SubSecondDelay(t *testing.T) {
+	te := New("test", 1, zap.NewNop())
+	te.Start()
+	defer te.Stop()
+
+	fired := make(chan struct{})
+	te.Schedule(TaskFunc(func(ctx context.Context) { close(fired) }), 100*time.Millisecond)
+
+	select {
+	case <-fired:
+	case <-time.After(3 * time.Second):
+		t.Fatal("sub-second timer task is not fired within a tick")
+	}
+}
@@ -0,0 +1,101 @@
+package trace
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/stn81/kate/log"
+	"github.com/stn81/kate/log/encoders/simple"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Exporter receives the finished spans.
+// ExportSpan is called on the goroutine ending the span, so it must not block.
+type Exporter interface {
+	ExportSpan(span *SpanData)
+	Shutdown(ctx context.Context) error
+}
+
+type exporterHolder struct {
+	Exporter
+}
+
+var exporter atomic.Pointer[exporterHolder]
+
+// SetExporter register the exporter of finished spans, nil disables exporting.
+// The previous exporter is not shutdown.
+func SetExporter(e Exporter) {
+	if e == nil {
+		exporter.Store(nil)
+		return
+	}
+	exporter.Store(&exporterHolder{e})
+}
+
+// Shutdown shutdown the registered exporter, flushing the pending spans
+func Shutdown(ctx context.Context) error {
+	if e := getExporter(); e != nil {
+		return e.Shutdown(ctx)
+	}
+	return nil
+}
+
+func getExporter() Exporter {
+	if h := exporter.Load(); h != nil {
+		return h.Exporter
+	}
+	return nil
+}
+
+// LogExporter writes every span as a log entry
+type LogExporter struct {
+	logger *zap.Logger
+}
+
+// NewLogExporter create a log exporter writing to logger
+func NewLogExporter(logger *zap.Logger) *LogExporter {
+	return &LogExporter{logger: logger}
+}
+
+// MustNewFileLogExporter create a log exporter writing to the file at location with the simple encoder,
+// a relative location is under the log dir of app home
+func MustNewFileLogExporter(location string) *LogExporter {
+	core := log.MustNewCoreWithLevelAbove(zapcore.InfoLevel, location, simple.NewEncoder())
+	return NewLogExporter(zap.New(core))
+}
+
+// ExportSpan implements Exporter
+func (e *LogExporter) ExportSpan(span *SpanData) {
+	fields := make([]zap.Field, 0, 10+len(span.Attributes))
+	fields = append(fields,
+		zap.String("trace_id", span.TraceId),
+		zap.String("span_id", span.SpanId),
+		zap.String("parent_span_id", span.ParentSpanId),
+		zap.String("name", span.Name),
+		zap.Stringer("kind", span.Kind),
+		zap.Time("start", span.StartTime),
+		zap.Duration("duration", span.Duration()),
+		zap.Stringer("status", span.StatusCode),
+	)
+	if span.StatusMessage != "" {
+		fields = append(fields, zap.String("status_message", span.StatusMessage))
+	}
+	for _, attr := range span.Attributes {
+		fields = append(fields, zap.Any("attr."+attr.Key, attr.Value))
+	}
+	if len(span.Events) > 0 {
+		events := make([]string, len(span.Events))
+		for i, event := range span.Events {
+			events[i] = event.Name
+		}
+		fields = append(fields, zap.Strings("events", events))
+	}
+	e.logger.Info("span", fields...)
+}
+
+// Shutdown implements Exporter
+func (e *LogExporter) Shutdown(context.Context) error {
+	_ = e.logger.Sync()
+	return nil
+}
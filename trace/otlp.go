@@ -0,0 +1,349 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// OTLPConfig is the config of OTLPExporter
+type OTLPConfig struct {
+	Endpoint      string            // collector base url, e.g. http://localhost:4318, spans are posted to /v1/traces
+	ServiceName   string            // resource attribute service.name
+	Headers       map[string]string // extra request headers, e.g. authorization
+	Timeout       time.Duration     // timeout per export request, default 10s
+	BatchSize     int               // max spans per export request, default 512
+	FlushInterval time.Duration     // max delay before exporting a partial batch, default 5s
+	QueueSize     int               // max pending spans, spans are dropped when full, default 2048
+	Client        *http.Client      // default http.DefaultClient
+}
+
+// OTLPExporter exports spans in batches to an OTLP/HTTP collector with JSON encoding
+type OTLPExporter struct {
+	conf    OTLPConfig
+	url     string
+	logger  *zap.Logger
+	queue   chan *SpanData
+	flushC  chan chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+	dropped atomic.Uint64
+}
+
+// NewOTLPExporter create an OTLP exporter, the background export loop is started
+func NewOTLPExporter(conf *OTLPConfig, logger *zap.Logger) *OTLPExporter {
+	e := &OTLPExporter{
+		conf:   *conf,
+		url:    strings.TrimRight(conf.Endpoint, "/") + "/v1/traces",
+		logger: logger.With(zap.String("exporter", "otlp")),
+	}
+	if e.conf.Timeout <= 0 {
+		e.conf.Timeout = 10 * time.Second
+	}
+	if e.conf.BatchSize <= 0 {
+		e.conf.BatchSize = 512
+	}
+	if e.conf.FlushInterval <= 0 {
+		e.conf.FlushInterval = 5 * time.Second
+	}
+	if e.conf.QueueSize <= 0 {
+		e.conf.QueueSize = 2048
+	}
+	if e.conf.Client == nil {
+		e.conf.Client = http.DefaultClient
+	}
+
+	e.queue = make(chan *SpanData, e.conf.QueueSize)
+	e.flushC = make(chan chan struct{})
+	e.done = make(chan struct{})
+
+	e.wg.Add(1)
+	go e.loop()
+	return e
+}
+
+// ExportSpan implements Exporter, the span is dropped if the queue is full or the exporter is shutdown
+func (e *OTLPExporter) ExportSpan(span *SpanData) {
+	select {
+	case <-e.done:
+		e.dropped.Add(1)
+		return
+	default:
+	}
+
+	select {
+	case e.queue <- span:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+// Dropped return the number of spans dropped
+func (e *OTLPExporter) Dropped() uint64 {
+	return e.dropped.Load()
+}
+
+// Flush export the pending spans
+func (e *OTLPExporter) Flush(ctx context.Context) error {
+	ack := make(chan struct{})
+	select {
+	case e.flushC <- ack:
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown implements Exporter, the pending spans are exported before return
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.once.Do(func() {
+		close(e.done)
+	})
+
+	finished := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *OTLPExporter) loop() {
+	defer e.wg.Done()
+
+	var (
+		batch  = make([]*SpanData, 0, e.conf.BatchSize)
+		ticker = time.NewTicker(e.conf.FlushInterval)
+	)
+	defer ticker.Stop()
+
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(batch); err != nil {
+			e.logger.Error("export spans failed", zap.Int("spans", len(batch)), zap.Error(err))
+		}
+		batch = batch[:0]
+	}
+
+	drain := func() {
+		for {
+			select {
+			case span := <-e.queue:
+				if batch = append(batch, span); len(batch) >= e.conf.BatchSize {
+					export()
+				}
+			default:
+				export()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case span := <-e.queue:
+			if batch = append(batch, span); len(batch) >= e.conf.BatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case ack := <-e.flushC:
+			drain()
+			close(ack)
+		case <-e.done:
+			drain()
+			return
+		}
+	}
+}
+
+func (e *OTLPExporter) export(spans []*SpanData) error {
+	body, err := json.Marshal(e.encode(spans))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.conf.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.conf.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.conf.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector responded %s", resp.Status)
+	}
+	return nil
+}
+
+// OTLP JSON encoding, see https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceId           string         `json:"traceId"`
+	SpanId            string         `json:"spanId"`
+	ParentSpanId      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+const otlpScopeName = "github.com/stn81/kate/trace"
+
+func (e *OTLPExporter) encode(spans []*SpanData) *otlpRequest {
+	var resource otlpResource
+	if e.conf.ServiceName != "" {
+		resource.Attributes = encodeAttributes([]Attribute{String("service.name", e.conf.ServiceName)})
+	}
+
+	encoded := make([]otlpSpan, len(spans))
+	for i, span := range spans {
+		encoded[i] = otlpSpan{
+			TraceId:           span.TraceId,
+			SpanId:            span.SpanId,
+			ParentSpanId:      span.ParentSpanId,
+			Name:              span.Name,
+			Kind:              int(span.Kind),
+			StartTimeUnixNano: unixNano(span.StartTime),
+			EndTimeUnixNano:   unixNano(span.EndTime),
+			Attributes:        encodeAttributes(span.Attributes),
+			Status:            otlpStatus{Code: int(span.StatusCode), Message: span.StatusMessage},
+		}
+		for _, event := range span.Events {
+			encoded[i].Events = append(encoded[i].Events, otlpEvent{
+				TimeUnixNano: unixNano(event.Time),
+				Name:         event.Name,
+				Attributes:   encodeAttributes(event.Attributes),
+			})
+		}
+	}
+
+	return &otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: resource,
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: otlpScopeName},
+				Spans: encoded,
+			}},
+		}},
+	}
+}
+
+func encodeAttributes(attrs []Attribute) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	kvs := make([]otlpKeyValue, len(attrs))
+	for i, attr := range attrs {
+		kvs[i].Key = attr.Key
+		switch v := attr.Value.(type) {
+		case string:
+			kvs[i].Value.StringValue = &v
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			kvs[i].Value.IntValue = &s
+		case float64:
+			kvs[i].Value.DoubleValue = &v
+		case bool:
+			kvs[i].Value.BoolValue = &v
+		default:
+			s := fmt.Sprint(v)
+			kvs[i].Value.StringValue = &s
+		}
+	}
+	return kvs
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
@@ -0,0 +1,325 @@
+// Package trace provides a lightweight span tracing API built on the W3C trace context
+// carried by package traceid. Finished spans are handed to the registered Exporter.
+package trace
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/stn81/kate/traceid"
+)
+
+// SpanKind describes the relationship between the span and its parent
+type SpanKind int
+
+// Span kinds, the values match OTLP
+const (
+	SpanKindInternal SpanKind = iota + 1
+	SpanKindServer
+	SpanKindClient
+	SpanKindProducer
+	SpanKindConsumer
+)
+
+// String implements fmt.Stringer
+func (k SpanKind) String() string {
+	switch k {
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	case SpanKindProducer:
+		return "producer"
+	case SpanKindConsumer:
+		return "consumer"
+	default:
+		return "internal"
+	}
+}
+
+// StatusCode is the status of a span, the values match OTLP
+type StatusCode int
+
+// Status codes
+const (
+	StatusUnset StatusCode = iota
+	StatusOk
+	StatusError
+)
+
+// String implements fmt.Stringer
+func (c StatusCode) String() string {
+	switch c {
+	case StatusOk:
+		return "ok"
+	case StatusError:
+		return "error"
+	default:
+		return "unset"
+	}
+}
+
+// Attribute is a key value pair attached to spans and events,
+// the value is one of string, int64, float64 and bool.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// String return a string attribute
+func String(key, value string) Attribute {
+	return Attribute{key, value}
+}
+
+// Int return an integer attribute
+func Int(key string, value int) Attribute {
+	return Attribute{key, int64(value)}
+}
+
+// Int64 return an integer attribute
+func Int64(key string, value int64) Attribute {
+	return Attribute{key, value}
+}
+
+// Float64 return a float attribute
+func Float64(key string, value float64) Attribute {
+	return Attribute{key, value}
+}
+
+// Bool return a boolean attribute
+func Bool(key string, value bool) Attribute {
+	return Attribute{key, value}
+}
+
+// Event is a timestamped annotation of a span
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes []Attribute
+}
+
+// SpanData is the read-only snapshot of a finished span
+type SpanData struct {
+	TraceId       string
+	SpanId        string
+	ParentSpanId  string
+	Name          string
+	Kind          SpanKind
+	StartTime     time.Time
+	EndTime       time.Time
+	Attributes    []Attribute
+	Events        []Event
+	StatusCode    StatusCode
+	StatusMessage string
+}
+
+// Duration return the span duration
+func (d *SpanData) Duration() time.Duration {
+	return d.EndTime.Sub(d.StartTime)
+}
+
+// Span is an operation within a trace, a span is safe for concurrent use.
+// Spans of unsampled traces are not recording, all the setters are no-op.
+type Span struct {
+	sc        traceid.SpanContext
+	recording bool
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+type spanCtxMarker struct{}
+
+var spanCtxMarkerKey = &spanCtxMarker{}
+
+// SpanOption configures StartSpan
+type SpanOption func(*Span)
+
+// WithKind set the span kind, default is SpanKindInternal
+func WithKind(kind SpanKind) SpanOption {
+	return func(s *Span) {
+		s.data.Kind = kind
+	}
+}
+
+// WithAttributes set the initial attributes
+func WithAttributes(attrs ...Attribute) SpanOption {
+	return func(s *Span) {
+		s.data.Attributes = append(s.data.Attributes, attrs...)
+	}
+}
+
+// WithStartTime override the start time
+func WithStartTime(t time.Time) SpanOption {
+	return func(s *Span) {
+		s.data.StartTime = t
+	}
+}
+
+// StartSpan start a span as the child of the span in ctx. Without a local span,
+// the span continues the remote trace in ctx (see traceid.SpanContextFrom), or starts a new trace.
+// The returned context carries the span and its SpanContext for propagation.
+func StartSpan(ctx context.Context, name string, opts ...SpanOption) (context.Context, *Span) {
+	var (
+		parent, _ = traceid.SpanContextFrom(ctx)
+		traceId   = traceid.Extract(ctx)
+	)
+
+	if !traceid.IsValidTraceId(parent.TraceId) {
+		parent = traceid.SpanContext{Flags: traceid.FlagSampled}
+		if traceId != "" {
+			parent.TraceId = traceid.FromLegacy(traceId)
+		} else {
+			parent.TraceId = traceid.New()
+		}
+	}
+
+	s := &Span{
+		sc: traceid.SpanContext{
+			TraceId: parent.TraceId,
+			SpanId:  traceid.NewSpanId(),
+			Flags:   parent.Flags,
+			State:   parent.State,
+		},
+		recording: parent.IsSampled(),
+		data: SpanData{
+			ParentSpanId: parent.SpanId,
+			Name:         name,
+			Kind:         SpanKindInternal,
+			StartTime:    time.Now(),
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.data.TraceId = s.sc.TraceId
+	s.data.SpanId = s.sc.SpanId
+
+	if traceId == "" {
+		ctx = traceid.ToContext(ctx, s.sc.TraceId)
+	}
+	ctx = traceid.WithSpanContext(ctx, s.sc)
+	ctx = context.WithValue(ctx, spanCtxMarkerKey, s)
+	return ctx, s
+}
+
+// FromContext return the current span in ctx, nil if absent.
+// All the methods of a nil span are no-op.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanCtxMarkerKey).(*Span)
+	return s
+}
+
+// SpanContext return the propagated context of the span
+func (s *Span) SpanContext() traceid.SpanContext {
+	if s == nil {
+		return traceid.SpanContext{}
+	}
+	return s.sc
+}
+
+// IsRecording check whether the span records data and will be exported
+func (s *Span) IsRecording() bool {
+	if s == nil || !s.recording {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.ended
+}
+
+// SetName rename the span
+func (s *Span) SetName(name string) {
+	s.update(func() {
+		s.data.Name = name
+	})
+}
+
+// SetAttributes set attributes, an existing key is overwritten
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	s.update(func() {
+	next:
+		for _, attr := range attrs {
+			for i := range s.data.Attributes {
+				if s.data.Attributes[i].Key == attr.Key {
+					s.data.Attributes[i].Value = attr.Value
+					continue next
+				}
+			}
+			s.data.Attributes = append(s.data.Attributes, attr)
+		}
+	})
+}
+
+// AddEvent add an event happened now
+func (s *Span) AddEvent(name string, attrs ...Attribute) {
+	s.update(func() {
+		s.data.Events = append(s.data.Events, Event{Name: name, Time: time.Now(), Attributes: attrs})
+	})
+}
+
+// SetStatus set the status, the message is kept only for StatusError
+func (s *Span) SetStatus(code StatusCode, msg string) {
+	s.update(func() {
+		s.data.StatusCode = code
+		if code == StatusError {
+			s.data.StatusMessage = msg
+		} else {
+			s.data.StatusMessage = ""
+		}
+	})
+}
+
+// RecordError add an exception event and set the error status, nil err is ignored
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.update(func() {
+		s.data.Events = append(s.data.Events, Event{
+			Name: "exception",
+			Time: time.Now(),
+			Attributes: []Attribute{
+				String("exception.type", fmt.Sprintf("%T", err)),
+				String("exception.message", err.Error()),
+			},
+		})
+		s.data.StatusCode = StatusError
+		s.data.StatusMessage = err.Error()
+	})
+}
+
+// End finish the span and export it, subsequent calls are ignored
+func (s *Span) End() {
+	if s == nil || !s.recording {
+		return
+	}
+
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTime = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if e := getExporter(); e != nil {
+		e.ExportSpan(&data)
+	}
+}
+
+func (s *Span) update(f func()) {
+	if s == nil || !s.recording {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		f()
+	}
+}
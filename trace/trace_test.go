@@ -0,0 +1,217 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stn81/kate/traceid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type recorder struct {
+	mu    sync.Mutex
+	spans []*SpanData
+}
+
+func (r *recorder) ExportSpan(span *SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, span)
+}
+
+func (r *recorder) Shutdown(context.Context) error {
+	return nil
+}
+
+func useRecorder(t *testing.T) *recorder {
+	r := &recorder{}
+	SetExporter(r)
+	t.Cleanup(func() {
+		SetExporter(nil)
+	})
+	return r
+}
+
+func TestStartSpan_ParentChild(t *testing.T) {
+	rec := useRecorder(t)
+
+	ctx, root := StartSpan(context.Background(), "root", WithKind(SpanKindServer))
+	_, child := StartSpan(ctx, "child", WithAttributes(String("db.system", "mysql")))
+
+	child.SetAttributes(Int("rows", 1), String("db.system", "redis"))
+	child.AddEvent("cache miss")
+	child.RecordError(errors.New("boom"))
+	child.End()
+	child.End()
+	root.SetStatus(StatusOk, "ignored")
+	root.End()
+
+	if len(rec.spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(rec.spans))
+	}
+	c, r := rec.spans[0], rec.spans[1]
+
+	if r.ParentSpanId != "" || r.Kind != SpanKindServer || r.StatusCode != StatusOk || r.StatusMessage != "" {
+		t.Errorf("unexpected root: %+v", r)
+	}
+	if c.TraceId != r.TraceId || c.ParentSpanId != r.SpanId {
+		t.Errorf("child not linked to root: child=%+v root=%+v", c, r)
+	}
+	if len(c.Attributes) != 2 || c.Attributes[0].Value != "redis" || c.Attributes[1].Value != int64(1) {
+		t.Errorf("unexpected attributes: %+v", c.Attributes)
+	}
+	if len(c.Events) != 2 || c.Events[0].Name != "cache miss" || c.Events[1].Name != "exception" {
+		t.Errorf("unexpected events: %+v", c.Events)
+	}
+	if c.StatusCode != StatusError || c.StatusMessage != "boom" {
+		t.Errorf("unexpected status: %v %q", c.StatusCode, c.StatusMessage)
+	}
+	if traceid.Extract(ctx) != r.TraceId {
+		t.Errorf("trace id not in context")
+	}
+	if sc, _ := traceid.SpanContextFrom(ctx); sc.SpanId != r.SpanId || sc.Remote {
+		t.Errorf("span context not in context: %+v", sc)
+	}
+	if FromContext(ctx) != root {
+		t.Errorf("span not in context")
+	}
+}
+
+func TestStartSpan_RemoteParent(t *testing.T) {
+	rec := useRecorder(t)
+
+	remote, _ := traceid.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := traceid.WithSpanContext(traceid.ToContext(context.Background(), remote.TraceId), remote)
+
+	_, span := StartSpan(ctx, "server")
+	span.End()
+
+	if got := rec.spans[0]; got.TraceId != remote.TraceId || got.ParentSpanId != remote.SpanId {
+		t.Errorf("remote parent not continued: %+v", got)
+	}
+}
+
+func TestStartSpan_NotSampled(t *testing.T) {
+	rec := useRecorder(t)
+
+	remote, _ := traceid.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	ctx := traceid.WithSpanContext(context.Background(), remote)
+
+	ctx, span := StartSpan(ctx, "server")
+	span.SetAttributes(String("k", "v"))
+	span.End()
+
+	if span.IsRecording() || len(rec.spans) != 0 {
+		t.Errorf("unsampled span should not be exported")
+	}
+	if sc, _ := traceid.SpanContextFrom(ctx); sc.TraceId != remote.TraceId || sc.IsSampled() {
+		t.Errorf("unsampled span context should still propagate: %+v", sc)
+	}
+}
+
+func TestNilSpan(t *testing.T) {
+	span := FromContext(context.Background())
+	span.SetAttributes(String("k", "v"))
+	span.AddEvent("e")
+	span.RecordError(errors.New("e"))
+	span.End()
+}
+
+func TestLogExporter(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	SetExporter(NewLogExporter(zap.New(core)))
+	defer SetExporter(nil)
+
+	_, span := StartSpan(context.Background(), "query", WithAttributes(String("db.system", "mysql")))
+	span.End()
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("logged %d entries, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["name"] != "query" || fields["attr.db.system"] != "mysql" || fields["span_id"] != span.SpanContext().SpanId {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	received := make(chan otlpRequest, 4)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" ||
+			r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected request: %s %v", r.URL.Path, r.Header)
+		}
+		body, _ := io.ReadAll(r.Body)
+
+		var req otlpRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("invalid body: %v, %s", err, body)
+		}
+		received <- req
+	}))
+	defer collector.Close()
+
+	exporter := NewOTLPExporter(&OTLPConfig{
+		Endpoint:      collector.URL + "/",
+		ServiceName:   "demo",
+		Headers:       map[string]string{"Authorization": "Bearer token"},
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	}, zap.NewNop())
+	SetExporter(exporter)
+	defer SetExporter(nil)
+
+	ctx, root := StartSpan(context.Background(), "root", WithKind(SpanKindServer))
+	_, child := StartSpan(ctx, "child", WithAttributes(Int("n", 7), Bool("ok", true)))
+	child.End()
+	root.End()
+
+	// 满批次立即导出
+	var req otlpRequest
+	select {
+	case req = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch not exported")
+	}
+
+	rs := req.ResourceSpans[0]
+	if v := rs.Resource.Attributes[0]; v.Key != "service.name" || *v.Value.StringValue != "demo" {
+		t.Errorf("unexpected resource: %+v", rs.Resource)
+	}
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 2 || spans[0].Name != "child" || spans[0].ParentSpanId != spans[1].SpanId ||
+		spans[1].Kind != int(SpanKindServer) || *spans[0].Attributes[0].Value.IntValue != "7" {
+		t.Errorf("unexpected spans: %+v", spans)
+	}
+
+	// Shutdown 导出剩余的 span
+	_, last := StartSpan(context.Background(), "last")
+	last.End()
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case req = <-received:
+		if name := req.ResourceSpans[0].ScopeSpans[0].Spans[0].Name; name != "last" {
+			t.Errorf("flushed span = %q", name)
+		}
+	default:
+		t.Fatal("pending span not exported on shutdown")
+	}
+
+	last.End()
+	_, after := StartSpan(context.Background(), "after")
+	after.End()
+	if exporter.Dropped() != 1 {
+		t.Errorf("Dropped() = %d, want 1", exporter.Dropped())
+	}
+}
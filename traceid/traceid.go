@@ -1,19 +1,161 @@
+// Package traceid carries the trace id of a request, it implements the W3C Trace Context
+// (https://www.w3.org/TR/trace-context/) propagation alongside the legacy X-Trace-Id header.
 package traceid
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Propagation headers
+const (
+	HeaderTraceId     = "X-Trace-Id"
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+)
+
+// FlagSampled is the sampled bit of the trace flags
+const FlagSampled byte = 0x01
 
-	"github.com/stn81/kate/utils"
+// ErrInvalidTraceparent is returned when the traceparent header is malformed
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+const (
+	traceIdLen     = 32
+	spanIdLen      = 16
+	traceparentLen = 55
 )
 
 type ctxMarker struct{}
 
 var ctxMarkerKey = &ctxMarker{}
 
+type spanCtxMarker struct{}
+
+var spanCtxMarkerKey = &spanCtxMarker{}
+
+// New return a W3C trace id, 32 lowercase hex characters
 func New() string {
-	return utils.FastUuidStr()
+	return randomHex(traceIdLen / 2)
+}
+
+// NewSpanId return a W3C span id, 16 lowercase hex characters
+func NewSpanId() string {
+	return randomHex(spanIdLen / 2)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	for {
+		_, _ = rand.Read(b)
+		for _, c := range b {
+			if c != 0 {
+				return hex.EncodeToString(b)
+			}
+		}
+	}
+}
+
+// IsValidTraceId check whether id is a W3C trace id
+func IsValidTraceId(id string) bool {
+	return len(id) == traceIdLen && isLowerHex(id) && !isAllZero(id)
+}
+
+// IsValidSpanId check whether id is a W3C span id
+func IsValidSpanId(id string) bool {
+	return len(id) == spanIdLen && isLowerHex(id) && !isAllZero(id)
 }
 
+// FromLegacy map a legacy X-Trace-Id value to a W3C trace id.
+// W3C trace ids (in any case) are kept as is, other values are hashed, so the same
+// legacy id always maps to the same trace id.
+func FromLegacy(traceId string) string {
+	if lower := strings.ToLower(traceId); IsValidTraceId(lower) {
+		return lower
+	}
+	sum := sha256.Sum256([]byte(traceId))
+	return hex.EncodeToString(sum[:traceIdLen/2])
+}
+
+// SpanContext is the propagated part of a trace
+type SpanContext struct {
+	TraceId string
+	SpanId  string
+	Flags   byte
+	State   string // raw tracestate, passed through untouched
+	Remote  bool   // SpanId belongs to the caller
+}
+
+// IsValid check whether both the trace id and span id are valid
+func (sc SpanContext) IsValid() bool {
+	return IsValidTraceId(sc.TraceId) && IsValidSpanId(sc.SpanId)
+}
+
+// IsSampled check whether the sampled flag is set
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&FlagSampled != 0
+}
+
+// Traceparent return the traceparent header value
+func (sc SpanContext) Traceparent() string {
+	const hexDigits = "0123456789abcdef"
+
+	var b strings.Builder
+	b.Grow(traceparentLen)
+	b.WriteString("00-")
+	b.WriteString(sc.TraceId)
+	b.WriteByte('-')
+	b.WriteString(sc.SpanId)
+	b.WriteByte('-')
+	b.WriteByte(hexDigits[sc.Flags>>4])
+	b.WriteByte(hexDigits[sc.Flags&0x0f])
+	return b.String()
+}
+
+// ParseTraceparent parse the traceparent header value, the returned SpanContext is marked as remote.
+// Future versions are accepted as long as the version 00 prefix is well formed.
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+
+	s = strings.TrimSpace(s)
+	if len(s) < traceparentLen {
+		return sc, ErrInvalidTraceparent
+	}
+
+	version := s[0:2]
+	if !isLowerHex(version) || version == "ff" || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return sc, ErrInvalidTraceparent
+	}
+	if version == "00" && len(s) != traceparentLen {
+		return sc, ErrInvalidTraceparent
+	}
+	if len(s) > traceparentLen && s[traceparentLen] != '-' {
+		return sc, ErrInvalidTraceparent
+	}
+
+	flags, err := hex.DecodeString(s[53:55])
+	if err != nil || !isLowerHex(s[53:55]) {
+		return sc, ErrInvalidTraceparent
+	}
+
+	sc = SpanContext{
+		TraceId: s[3:35],
+		SpanId:  s[36:52],
+		Flags:   flags[0],
+		Remote:  true,
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	return sc, nil
+}
+
+// Extract return the trace id in ctx, empty if absent
 func Extract(ctx context.Context) string {
 	if traceId, ok := ctx.Value(ctxMarkerKey).(string); ok {
 		return traceId
@@ -21,6 +163,65 @@ func Extract(ctx context.Context) string {
 	return ""
 }
 
+// ToContext return a copy of ctx carrying traceId
 func ToContext(ctx context.Context, traceId string) context.Context {
 	return context.WithValue(ctx, ctxMarkerKey, traceId)
 }
+
+// SpanContextFrom return the SpanContext in ctx
+func SpanContextFrom(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanCtxMarkerKey).(SpanContext)
+	return sc, ok
+}
+
+// WithSpanContext return a copy of ctx carrying sc
+func WithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanCtxMarkerKey, sc)
+}
+
+// Inject write the propagation headers of ctx into header for an outgoing request.
+// A remote SpanContext (no local span started) is continued with a new span id.
+func Inject(ctx context.Context, header http.Header) {
+	traceId := Extract(ctx)
+
+	sc, ok := SpanContextFrom(ctx)
+	if !ok && traceId != "" {
+		sc = SpanContext{TraceId: FromLegacy(traceId), Flags: FlagSampled, Remote: true}
+	}
+	if sc.Remote || sc.SpanId == "" {
+		sc.SpanId = NewSpanId()
+	}
+
+	if IsValidTraceId(sc.TraceId) {
+		header.Set(HeaderTraceparent, sc.Traceparent())
+		if sc.State != "" {
+			header.Set(HeaderTracestate, sc.State)
+		}
+	}
+
+	if traceId == "" {
+		traceId = sc.TraceId
+	}
+	if traceId != "" {
+		header.Set(HeaderTraceId, traceId)
+	}
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isAllZero(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '0' {
+			return false
+		}
+	}
+	return true
+}
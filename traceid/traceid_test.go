@@ -0,0 +1,101 @@
+package traceid
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	if id := New(); !IsValidTraceId(id) {
+		t.Errorf("New() = %q, not a W3C trace id", id)
+	}
+	if id := NewSpanId(); !IsValidSpanId(id) {
+		t.Errorf("NewSpanId() = %q, not a W3C span id", id)
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	const valid = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	sc, err := ParseTraceparent(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.TraceId != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanId != "00f067aa0ba902b7" ||
+		!sc.IsSampled() || !sc.Remote {
+		t.Errorf("unexpected span context: %+v", sc)
+	}
+	if got := sc.Traceparent(); got != valid {
+		t.Errorf("Traceparent() = %q, want %q", got, valid)
+	}
+
+	for _, s := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0g",
+	} {
+		if _, err := ParseTraceparent(s); err == nil {
+			t.Errorf("ParseTraceparent(%q) should fail", s)
+		}
+	}
+
+	// 未来版本允许在尾部追加字段
+	if _, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"); err != nil {
+		t.Errorf("future version: %v", err)
+	}
+}
+
+func TestFromLegacy(t *testing.T) {
+	if got := FromLegacy("4BF92F3577B34DA6A3CE929D0E0E4736"); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("W3C id should be kept, got %q", got)
+	}
+
+	legacy := "a5c3f0e1d2b4a6c8e0f1a2b3c4d5e6f7a8b9c0d1e2f3a4b5"
+	if got := FromLegacy(legacy); !IsValidTraceId(got) || got != FromLegacy(legacy) {
+		t.Errorf("FromLegacy(%q) = %q, want a stable W3C id", legacy, got)
+	}
+}
+
+func TestInject(t *testing.T) {
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	remote.State = "congo=t61rcWkgMzE"
+
+	ctx := ToContext(context.Background(), remote.TraceId)
+	ctx = WithSpanContext(ctx, remote)
+
+	header := make(http.Header)
+	Inject(ctx, header)
+
+	sc, err := ParseTraceparent(header.Get(HeaderTraceparent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.TraceId != remote.TraceId || sc.SpanId == remote.SpanId || sc.Flags != remote.Flags {
+		t.Errorf("unexpected injected span context: %+v", sc)
+	}
+	if got := header.Get(HeaderTracestate); got != remote.State {
+		t.Errorf("tracestate = %q, want %q", got, remote.State)
+	}
+	if got := header.Get(HeaderTraceId); got != remote.TraceId {
+		t.Errorf("X-Trace-Id = %q, want %q", got, remote.TraceId)
+	}
+}
+
+func TestInject_LegacyOnly(t *testing.T) {
+	ctx := ToContext(context.Background(), "legacy-id")
+
+	header := make(http.Header)
+	Inject(ctx, header)
+
+	if got := header.Get(HeaderTraceId); got != "legacy-id" {
+		t.Errorf("X-Trace-Id = %q", got)
+	}
+	if sc, err := ParseTraceparent(header.Get(HeaderTraceparent)); err != nil || sc.TraceId != FromLegacy("legacy-id") {
+		t.Errorf("traceparent = %q, err = %v", header.Get(HeaderTraceparent), err)
+	}
+}